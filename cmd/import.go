@@ -0,0 +1,138 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package cmd
+
+import (
+	"ask/db"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importHistoryCmd)
+	importHistoryCmd.Flags().IntP("limit", "n", 20, "Maximum number of import runs to show")
+	importHistoryCmd.Flags().StringP("airport", "a", "", "Only show the imports that added or removed this airport ident")
+	importCmd.AddCommand(importIssuesCmd)
+	importIssuesCmd.Flags().IntP("limit", "n", 100, "Maximum number of issues to show")
+	importIssuesCmd.Flags().StringP("check", "c", "", "Only show the issues of this check")
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Inspect the imports of the local database",
+	Long:  `Commands about the imports that built the local database`,
+}
+
+var importHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the history of the imports",
+	Long: `Will list the past imports, newest first, with their source commit,
+record counts, warnings and the airports they added or removed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		airport, _ := cmd.Flags().GetString("airport")
+		doImportHistory(strings.ToUpper(airport), limit)
+	},
+}
+
+var importIssuesCmd = &cobra.Command{
+	Use:   "issues",
+	Short: "Show the data quality issues found by the last import",
+	Long: `Will list the problems found in the imported data: duplicate codes,
+invalid or swapped coordinates, unknown countries, malformed ICAO codes
+and implausible elevations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		check, _ := cmd.Flags().GetString("check")
+		doImportIssues(check, limit)
+	},
+}
+
+// bindStrictFlag binds the --strict flag of the running command to the import.strict config key.
+// It is bound when the command runs since both init and update declare it.
+func bindStrictFlag(cmd *cobra.Command) {
+	viper.BindPFlag("import.strict", cmd.Flags().Lookup("strict"))
+}
+
+func doImportHistory(airport string, limit int) {
+	runs, err := db.LiveImportHistory(airport, limit)
+	if err != nil {
+		fmt.Printf("Error reading import history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(runs) == 0 {
+		if airport != "" {
+			fmt.Printf("No import added or removed %s\n", airport)
+		} else {
+			fmt.Println("No import recorded yet")
+		}
+		return
+	}
+
+	for _, run := range runs {
+		source := shortHash(run.GitCommitHash)
+		if source == "" && len(run.ContentChecksum) > 19 {
+			source = run.ContentChecksum[:19]
+		}
+
+		fmt.Printf("#%d %s %s %s (%dms)\n", run.ID, run.StartedAt, run.Outcome, source, run.DurationMs)
+		if run.Error != "" {
+			fmt.Printf("  error: %s\n", run.Error)
+		}
+
+		for _, table := range db.ImportedTables() {
+			if count, ok := run.RowCounts[table]; ok {
+				fmt.Printf("  %-12s %8d\n", table, count)
+			}
+		}
+
+		if airport != "" {
+			if slices.Contains(run.AirportsAdded, airport) {
+				fmt.Printf("  added %s\n", airport)
+			}
+			if slices.Contains(run.AirportsRemoved, airport) {
+				fmt.Printf("  removed %s\n", airport)
+			}
+		} else if len(run.AirportsAdded) > 0 || len(run.AirportsRemoved) > 0 {
+			fmt.Printf("  airports: %d added, %d removed\n", len(run.AirportsAdded), len(run.AirportsRemoved))
+		}
+
+		for _, warning := range run.Warnings {
+			fmt.Printf("  warning: %s\n", warning)
+		}
+	}
+}
+
+func doImportIssues(check string, limit int) {
+	if check != "" && !db.IsDataQualityCheck(check) {
+		fmt.Printf("Error: unknown check %q\n", check)
+		os.Exit(1)
+	}
+
+	issues, total, err := db.LiveImportIssues(check, limit)
+	if err != nil {
+		fmt.Printf("Error reading data quality issues: %v\n", err)
+		os.Exit(1)
+	}
+
+	if total == 0 {
+		fmt.Println("No data quality issue found")
+		return
+	}
+
+	for _, issue := range issues {
+		fmt.Printf("%-20s %-10s %s\n", issue.Check, issue.Ident, issue.Message)
+	}
+
+	if total > len(issues) {
+		fmt.Printf("... %d of %d issues shown\n", len(issues), total)
+	}
+}
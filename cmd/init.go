@@ -10,33 +10,102 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func init() {
 	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().String("from-dir", "", "Import the CSV files of a local directory instead of pulling them from git")
+	initCmd.Flags().String("from-archive", "", "Import the CSV files of a local .tar.gz or .zip archive instead of pulling them from git")
+	initCmd.Flags().String("from-openflights", "", "Build the database from an OpenFlights airports.dat file (and the countries.dat file next to it) instead of the ourairports data")
+	initCmd.MarkFlagsMutuallyExclusive("from-dir", "from-archive", "from-openflights")
+	initCmd.Flags().String("source-url", "", "URL of the git repository to pull the data from (default is the ourairports-data GitHub repository)")
+	initCmd.Flags().String("branch", "", "Branch of the data repository to follow (default is the remote default branch)")
+	initCmd.Flags().String("ref", "", "Commit or tag of the data repository to check out instead of following a branch")
+	initCmd.Flags().Bool("strict", false, "Fail the import when the data quality checks find issues")
+
+	viper.BindPFlag("source.url", initCmd.Flags().Lookup("source-url"))
+	viper.BindPFlag("source.branch", initCmd.Flags().Lookup("branch"))
+	viper.BindPFlag("source.ref", initCmd.Flags().Lookup("ref"))
 }
 
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the local database",
-	Long:  `Will download the data and setup a local database`,
+	Long: `Will download the data and setup a local database.
+
+For offline setups, the data can be read from a local directory (--from-dir)
+or archive (--from-archive) holding the ourairports CSV files instead.
+
+The database can also be built from the OpenFlights dataset (--from-openflights),
+which only provides airports and countries.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindStrictFlag(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		doInit()
+		fromDir, _ := cmd.Flags().GetString("from-dir")
+		fromArchive, _ := cmd.Flags().GetString("from-archive")
+		fromOpenFlights, _ := cmd.Flags().GetString("from-openflights")
+		doInit(fromDir, fromArchive, fromOpenFlights)
 	},
 }
 
-func doInit() {
+func doInit(fromDir string, fromArchive string, fromOpenFlights string) {
 	// First, ensure the repo directory exists
 	repository.EnsureRepositoryDirExists()
 
-	// Then, ensure the data sub dir exists
-	repository.EnsureDataDirExists()
+	dataDir := repository.DataDir()
+	var source db.DataSource
+
+	switch {
+	case fromOpenFlights != "":
+		// Use the OpenFlights file as is
+		if _, err := os.Stat(fromOpenFlights); err != nil {
+			fmt.Printf("Error: OpenFlights file %s doesn't exist\n", fromOpenFlights)
+			os.Exit(1)
+		}
+		source = db.NewOpenFlightsSource(fromOpenFlights)
+
+	case fromDir != "":
+		// Use the local data as is
+		if !repository.IsDirectoryExists(fromDir) {
+			fmt.Printf("Error: data directory %s doesn't exist\n", fromDir)
+			os.Exit(1)
+		}
+		dataDir = fromDir
+
+	case fromArchive != "":
+		// Extract the local archive
+		extractedDir, err := repository.ExtractArchive(fromArchive)
+		if err != nil {
+			fmt.Printf("Error extracting data archive: %v\n", err)
+			os.Exit(1)
+		}
+		dataDir = extractedDir
 
-	// Get the data
-	repository.RetrieveDataFromGit()
+	default:
+		// Then, ensure the data sub dir exists
+		repository.EnsureDataDirExists()
+
+		// Get the data
+		err := repository.RetrieveDataFromGit()
+		if err != nil {
+			fmt.Printf("Error retrieving data: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if source == nil {
+		source = db.NewOurAirportsSource(dataDir)
+	}
 
 	// Initialize the database and import airport data
-	err := db.InitializeDatabase()
+	err := db.InitializeDatabase(source)
+
+	if fromArchive != "" {
+		os.RemoveAll(dataDir)
+	}
+
 	if err != nil {
 		fmt.Printf("Error initializing database: %v\n", err)
 		os.Exit(1)
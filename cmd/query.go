@@ -4,31 +4,393 @@ Copyright © 2024 Nicolas Dufour
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"ask/db"
 	"ask/repository"
+	"ask/server"
+	"ask/service"
 
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(queryCmd)
+	queryCmd.PersistentFlags().Bool("json", false, "Print the same JSON document as the HTTP API")
+
+	queryCmd.AddCommand(queryAirportCmd)
+	queryCmd.AddCommand(querySearchCmd)
+	querySearchCmd.Flags().StringP("country", "c", "", "Only search the airports of this country code")
+	queryCmd.AddCommand(queryDistanceCmd)
+	queryCmd.AddCommand(queryReachableCmd)
+	queryReachableCmd.Flags().String("range", "", "Range in nautical miles")
+	queryReachableCmd.MarkFlagRequired("range")
+	queryReachableCmd.Flags().StringP("type", "t", "", "Comma-separated airport types to keep")
+	queryReachableCmd.Flags().String("country", "", "Comma-separated country codes to keep")
+	queryReachableCmd.Flags().String("exclude-country", "", "Comma-separated country codes to leave out")
+	queryReachableCmd.Flags().String("continent", "", "Comma-separated continent codes to keep")
+	queryReachableCmd.Flags().String("exclude-continent", "", "Comma-separated continent codes to leave out")
+	queryReachableCmd.Flags().Bool("scheduled", false, "Only keep the airports with a scheduled airline service")
+	queryReachableCmd.Flags().Int("min-elevation", 0, "Minimum elevation in feet")
+	queryReachableCmd.Flags().Int("max-elevation", 0, "Maximum elevation in feet")
+	queryReachableCmd.Flags().Int("min-runway", 0, "Minimum length in feet of an open runway")
+	queryCmd.AddCommand(queryTimeCmd)
 }
 
 var queryCmd = &cobra.Command{
 	Use:   "query",
 	Short: "Query the database",
-	Long:  `Allow multiple types of queries on the database`,
+	Long: `Allow multiple types of queries on the local database,
+without having to start the server`,
+}
+
+var queryAirportCmd = &cobra.Command{
+	Use:   "airport <code>",
+	Short: "Show an airport and its runways",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		doQueryAirport(args[0], jsonOutput(cmd))
+	},
+}
+
+var querySearchCmd = &cobra.Command{
+	Use:   "search <name>",
+	Short: "Search airports by name, city or code",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		country, _ := cmd.Flags().GetString("country")
+		doQuerySearch(strings.Join(args, " "), country, jsonOutput(cmd))
+	},
+}
+
+var queryDistanceCmd = &cobra.Command{
+	Use:   "distance <from> <to>",
+	Short: "Compute the distance between two airports",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		doQueryDistance(args[0], args[1], jsonOutput(cmd))
+	},
+}
+
+var queryReachableCmd = &cobra.Command{
+	Use:   "reachable <code>",
+	Short: "List the airports within range of an airport",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rangeStr, _ := cmd.Flags().GetString("range")
+		doQueryReachable(args[0], rangeStr, reachableFilter(cmd), jsonOutput(cmd))
+	},
+}
+
+var queryTimeCmd = &cobra.Command{
+	Use:   "time <code>",
+	Short: "Show the local time at an airport",
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		doQuery()
+		doQueryTime(args[0], jsonOutput(cmd))
 	},
 }
 
-func doQuery() {
+func jsonOutput(cmd *cobra.Command) bool {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	return asJSON
+}
+
+// openQueryStore opens the airport store of the live database, exiting when it cannot be queried
+func openQueryStore() *service.SQLiteStore {
 	if !repository.IsRepositoryDirectoryExists() {
 		fmt.Println("Warning! the repository doesn't exist!")
 		fmt.Println("Please, set it up with the `init` command")
 		os.Exit(1)
 	}
+
+	store, err := service.OpenSQLiteStore(db.DatabasePath())
+	if err != nil {
+		fmt.Printf("Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+// queryAirport resolves an airport code of any kind, exiting when it is unknown or ambiguous
+func queryAirport(store service.AirportStore, code string) *service.CodeMatch {
+	if !service.IsValidAirportCode(code) {
+		fmt.Printf("Error: invalid airport code %q - must be 1 to 10 letters, digits or hyphens\n", code)
+		os.Exit(1)
+	}
+
+	match, err := store.ResolveCode(code)
+	var ambiguous *service.AmbiguousCodeError
+	if errors.As(err, &ambiguous) {
+		fmt.Printf("Error: %v, use one of their idents:\n", err)
+		for _, candidate := range ambiguous.Candidates {
+			fmt.Printf("  %s (%s code)\n", airportSummary(candidate.Airport), candidate.Kind)
+		}
+		os.Exit(1)
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		fmt.Printf("Error: airport %s not found\n", strings.ToUpper(code))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error retrieving airport: %v\n", err)
+		os.Exit(1)
+	}
+	return match
+}
+
+// printJSON prints the response as the HTTP API encodes it
+func printJSON(response interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		fmt.Printf("Error encoding response: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// airportSummary formats the codes, name and location of an airport on one line
+func airportSummary(airport service.Airport) string {
+	codes := airport.Ident
+	if airport.IataCode != "" {
+		codes += "/" + airport.IataCode
+	}
+
+	location := airport.IsoCountry
+	if airport.Municipality != "" {
+		location = airport.Municipality + ", " + location
+	}
+
+	return fmt.Sprintf("%-12s %s (%s)", codes, airport.Name, location)
+}
+
+func doQueryAirport(code string, asJSON bool) {
+	store := openQueryStore()
+	defer store.Close()
+
+	match := queryAirport(store, code)
+	airport := &match.Airport
+
+	if asJSON {
+		// As /api/airports/{code}, with the frequencies
+		airports := []service.Airport{*airport}
+		if err := store.AttachFrequencies(airports); err != nil {
+			fmt.Printf("Error retrieving frequencies: %v\n", err)
+			os.Exit(1)
+		}
+		printJSON(server.AirportResponse{
+			Airport: airports[0],
+			Match:   match.Kind,
+		})
+		return
+	}
+
+	fmt.Println(airportSummary(*airport))
+	fmt.Printf("  matched by:  %s code\n", match.Kind)
+	fmt.Printf("  type:        %s\n", airport.Type)
+	if airport.RegionName != "" {
+		fmt.Printf("  region:      %s (%s)\n", airport.RegionName, airport.IsoRegion)
+	}
+	fmt.Printf("  position:    %.6f, %.6f\n", airport.LatitudeDeg, airport.LongitudeDeg)
+	fmt.Printf("  elevation:   %d ft\n", airport.ElevationFt)
+	if airport.Timezone != "" {
+		fmt.Printf("  timezone:    %s\n", airport.Timezone)
+	}
+	fmt.Printf("  scheduled:   %s\n", airport.ScheduledService)
+
+	for _, runway := range airport.Runways {
+		status := ""
+		if runway.Closed {
+			status = " closed"
+		}
+		fmt.Printf("  runway %s/%s: %d x %d ft %s%s\n", runway.LowEnd.Ident, runway.HighEnd.Ident,
+			runway.LengthFt, runway.WidthFt, runway.Surface, status)
+	}
+}
+
+func doQuerySearch(name string, country string, asJSON bool) {
+	if !service.IsValidSearchText(name) {
+		fmt.Println("Error: invalid name - only letters, digits, spaces, hyphens, and apostrophes are allowed")
+		os.Exit(1)
+	}
+	if country != "" && !service.IsValidCountryCode(country) {
+		fmt.Println("Error: invalid country - only letters are allowed")
+		os.Exit(1)
+	}
+
+	store := openQueryStore()
+	defer store.Close()
+
+	airports, err := store.SearchAirports(name, country)
+	if err != nil {
+		fmt.Printf("Error searching airports: %v\n", err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		printJSON(server.SearchResponse{
+			Airports:   airports,
+			Count:      len(airports),
+			Pagination: server.Pagination{Total: len(airports), Limit: len(airports)},
+		})
+		return
+	}
+
+	if len(airports) == 0 {
+		fmt.Println("No airport found")
+		return
+	}
+
+	for _, airport := range airports {
+		fmt.Printf("%s [%s]\n", airportSummary(airport), airport.Type)
+	}
+}
+
+func doQueryDistance(from string, to string, asJSON bool) {
+	store := openQueryStore()
+	defer store.Close()
+
+	departure := queryAirport(store, from)
+	destination := queryAirport(store, to)
+
+	distance := service.Distance(
+		departure.Airport.LatitudeDeg, departure.Airport.LongitudeDeg,
+		destination.Airport.LatitudeDeg, destination.Airport.LongitudeDeg,
+	)
+
+	if asJSON {
+		printJSON(server.DistanceResponse{
+			DepartureAirport:   departure.Airport,
+			DepartureMatch:     departure.Kind,
+			DestinationAirport: destination.Airport,
+			DestinationMatch:   destination.Kind,
+			DistanceNM:         distance,
+		})
+		return
+	}
+
+	fmt.Println(airportSummary(departure.Airport))
+	fmt.Println(airportSummary(destination.Airport))
+	fmt.Printf("Distance: %.1f NM\n", distance)
+}
+
+// reachableFilter builds the airport filter of the reachable flags, exiting when one is invalid
+func reachableFilter(cmd *cobra.Command) service.AirportFilter {
+	var filter service.AirportFilter
+	var ok bool
+
+	types, _ := cmd.Flags().GetString("type")
+	filter.Types, ok = service.ParseAirportTypes(types)
+	if !ok {
+		fmt.Println("Error: invalid airport type - valid types are: large_airport, medium_airport, small_airport, heliport, seaplane_base, closed, balloonport")
+		os.Exit(1)
+	}
+
+	countries, _ := cmd.Flags().GetString("country")
+	filter.Countries, ok = service.ParseCountryCodes(countries)
+	if !ok {
+		fmt.Println("Error: invalid country - must be comma-separated country codes")
+		os.Exit(1)
+	}
+
+	excludedCountries, _ := cmd.Flags().GetString("exclude-country")
+	filter.ExcludedCountries, ok = service.ParseCountryCodes(excludedCountries)
+	if !ok {
+		fmt.Println("Error: invalid exclude-country - must be comma-separated country codes")
+		os.Exit(1)
+	}
+
+	continents, _ := cmd.Flags().GetString("continent")
+	filter.Continents, ok = service.ParseContinents(continents)
+	if !ok {
+		fmt.Println("Error: invalid continent - valid continents are: AF, AN, AS, EU, NA, OC, SA")
+		os.Exit(1)
+	}
+
+	excludedContinents, _ := cmd.Flags().GetString("exclude-continent")
+	filter.ExcludedContinents, ok = service.ParseContinents(excludedContinents)
+	if !ok {
+		fmt.Println("Error: invalid exclude-continent - valid continents are: AF, AN, AS, EU, NA, OC, SA")
+		os.Exit(1)
+	}
+
+	filter.ScheduledService, _ = cmd.Flags().GetBool("scheduled")
+
+	if cmd.Flags().Changed("min-elevation") {
+		elevation, _ := cmd.Flags().GetInt("min-elevation")
+		filter.MinElevationFt = &elevation
+	}
+	if cmd.Flags().Changed("max-elevation") {
+		elevation, _ := cmd.Flags().GetInt("max-elevation")
+		filter.MaxElevationFt = &elevation
+	}
+
+	filter.MinRunwayFt, _ = cmd.Flags().GetInt("min-runway")
+	if cmd.Flags().Changed("min-runway") && filter.MinRunwayFt <= 0 {
+		fmt.Println("Error: invalid min-runway - must be a positive number of feet")
+		os.Exit(1)
+	}
+
+	return filter
+}
+
+func doQueryReachable(code string, rangeStr string, filter service.AirportFilter, asJSON bool) {
+	rangeNM, ok := service.IsValidRange(rangeStr)
+	if !ok {
+		fmt.Println("Error: invalid range - must be a positive number up to 10800 NM")
+		os.Exit(1)
+	}
+
+	store := openQueryStore()
+	defer store.Close()
+
+	origin := queryAirport(store, code)
+
+	airports, err := store.AirportsInRange(&origin.Airport, rangeNM, filter)
+	if err != nil {
+		fmt.Printf("Error querying reachable airports: %v\n", err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		printJSON(server.ReachableResponse{
+			OriginAirport: origin.Airport,
+			OriginMatch:   origin.Kind,
+			RangeNM:       rangeNM,
+			Airports:      airports,
+			Count:         len(airports),
+			Pagination:    server.Pagination{Total: len(airports), Limit: len(airports)},
+		})
+		return
+	}
+
+	fmt.Printf("%d airport(s) within %g NM of %s\n", len(airports), rangeNM, origin.Airport.Ident)
+	for _, reachable := range airports {
+		fmt.Printf("%8.1f NM  %s [%s]\n", reachable.DistanceNM, airportSummary(reachable.Airport), reachable.Airport.Type)
+	}
+}
+
+func doQueryTime(code string, asJSON bool) {
+	store := openQueryStore()
+	defer store.Close()
+
+	match := queryAirport(store, code)
+
+	response, err := service.LocalTime(&match.Airport)
+	if err != nil {
+		fmt.Printf("Error determining the airport local time: %v\n", err)
+		os.Exit(1)
+	}
+	response.Match = match.Kind
+
+	if asJSON {
+		printJSON(response)
+		return
+	}
+
+	fmt.Printf("%s: %s (%s, UTC%s)\n", match.Airport.Ident, response.LocalTime, response.Timezone, response.UTCOffset)
 }
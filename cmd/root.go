@@ -51,6 +51,8 @@ func init() {
 
 	viper.SetDefault("data", "data")
 	viper.SetDefault("db", "db")
+	viper.SetDefault("source.url", "https://github.com/davidmegginson/ourairports-data")
+	viper.SetDefault("snapshots.keep", 5)
 }
 
 // initConfig reads in config file and ENV variables if set.
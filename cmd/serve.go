@@ -13,6 +13,7 @@ import (
 	"syscall"
 	"time"
 
+	"ask/db"
 	"ask/repository"
 	"ask/server"
 
@@ -24,6 +25,9 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	serveCmd.Flags().IntP("port", "p", 8080, "Port to run the server on")
 	viper.BindPFlag("server.port", serveCmd.Flags().Lookup("port"))
+	serveCmd.Flags().Int("max-results", server.DefaultMaxResults, "Maximum number of items returned by the list endpoints")
+	viper.BindPFlag("server.max_results", serveCmd.Flags().Lookup("max-results"))
+	serveCmd.Flags().String("snapshot", "", "Serve the database snapshot with this id instead of the live database")
 }
 
 var serveCmd = &cobra.Command{
@@ -31,19 +35,33 @@ var serveCmd = &cobra.Command{
 	Short: "Start a http server",
 	Long:  `Start a http server for remote queries`,
 	Run: func(cmd *cobra.Command, args []string) {
-		doServe()
+		snapshot, _ := cmd.Flags().GetString("snapshot")
+		doServe(snapshot)
 	},
 }
 
-func doServe() {
+func doServe(snapshot string) {
 	if !repository.IsRepositoryDirectoryExists() {
 		fmt.Println("Warning! the repository doesn't exist!")
 		fmt.Println("Please, set it up with the `init` command")
 		os.Exit(1)
 	}
 
+	dbPath := db.DatabasePath()
+	if snapshot != "" {
+		// Serve a copy, as opening the database may migrate it
+		var err error
+		dbPath, err = db.CopySnapshot(snapshot)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.Remove(dbPath)
+		log.Printf("Serving database snapshot %s", snapshot)
+	}
+
 	port := viper.GetInt("server.port")
-	srv := server.NewServer(port)
+	srv := server.NewServer(port, dbPath, viper.GetInt("server.max_results"))
 
 	go func() {
 		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
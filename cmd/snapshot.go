@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package cmd
+
+import (
+	"ask/db"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRollbackCmd)
+	snapshotCmd.AddCommand(snapshotPruneCmd)
+	snapshotPruneCmd.Flags().IntP("keep", "k", 0, "Number of snapshots to keep (default is the snapshots.keep config key)")
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage the database snapshots",
+	Long: `Every import keeps a snapshot of the database it built, tagged with its
+source commit. The last snapshots (snapshots.keep, 5 by default) are kept.`,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the database snapshots",
+	Long:  `Will list the database snapshots, newest first, marking the live one`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doSnapshotList()
+	},
+}
+
+var snapshotRollbackCmd = &cobra.Command{
+	Use:   "rollback <id>",
+	Short: "Install a database snapshot as the live database",
+	Long: `Will atomically replace the live database with the given snapshot.
+A running server picks it up within a few seconds.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		doSnapshotRollback(args[0])
+	},
+}
+
+var snapshotPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the oldest database snapshots",
+	Long:  `Will remove the oldest database snapshots, always keeping the live one`,
+	Run: func(cmd *cobra.Command, args []string) {
+		keep, _ := cmd.Flags().GetInt("keep")
+		if !cmd.Flags().Changed("keep") {
+			keep = db.SnapshotsToKeep()
+		}
+		doSnapshotPrune(keep)
+	},
+}
+
+func doSnapshotList() {
+	snapshots, err := db.ListSnapshots()
+	if err != nil {
+		fmt.Printf("Error listing snapshots: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshot yet, they are taken by `init` and `update`")
+		return
+	}
+
+	fmt.Printf("%-24s %-20s %-8s %8s\n", "ID", "CREATED", "SOURCE", "AIRPORTS")
+	for _, snapshot := range snapshots {
+		source := shortHash(snapshot.GitCommitHash)
+		if source == "" {
+			source = "checksum"
+		}
+
+		live := ""
+		if snapshot.Live {
+			live = "  (live)"
+		}
+
+		fmt.Printf("%-24s %-20s %-8s %8d%s\n", snapshot.ID, snapshot.CreatedAt.Format(time.RFC3339), source, snapshot.AirportCount, live)
+	}
+}
+
+func doSnapshotRollback(id string) {
+	err := db.RollbackSnapshot(id)
+	if err != nil {
+		fmt.Printf("Error rolling back: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Database rolled back to snapshot %s\n", id)
+}
+
+func doSnapshotPrune(keep int) {
+	if keep < 0 {
+		fmt.Println("Error: --keep must not be negative")
+		os.Exit(1)
+	}
+
+	removed, err := db.PruneSnapshots(keep)
+	for _, id := range removed {
+		fmt.Printf("Removed snapshot %s\n", id)
+	}
+	if err != nil {
+		fmt.Printf("Error pruning snapshots: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(removed) == 0 {
+		fmt.Println("Nothing to prune")
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package cmd
+
+import (
+	"ask/db"
+	"ask/repository"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// maxListedCommits caps the number of upstream commits printed by `ask update`
+const maxListedCommits = 20
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().BoolP("force", "f", false, "Rebuild the database even if the upstream commit did not change")
+	updateCmd.Flags().Bool("strict", false, "Fail the import when the data quality checks find issues")
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the local database if the upstream data changed",
+	Long: `Will pull the latest data and rebuild the local database,
+only if the upstream commit differs from the one of the last import.
+A database built from OpenFlights is rebuilt from the same airports.dat file,
+only if its content changed.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindStrictFlag(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		doUpdate(force)
+	},
+}
+
+func doUpdate(force bool) {
+	repository.EnsureRepositoryDirExists()
+
+	before, err := db.LiveImportStatus()
+	if err != nil {
+		fmt.Printf("Error reading current import status: %v\n", err)
+		os.Exit(1)
+	}
+
+	liveSource, err := db.LiveDataSource()
+	if err != nil {
+		fmt.Printf("Error reading current data source: %v\n", err)
+		os.Exit(1)
+	}
+	if liveSource == db.SourceOpenFlights {
+		doUpdateOpenFlights(before, force)
+		return
+	}
+
+	repository.EnsureDataDirExists()
+	importedCommit := importedCommitHash(before)
+
+	err = repository.RetrieveDataFromGit()
+	if err != nil {
+		fmt.Printf("Error retrieving data: %v\n", err)
+		os.Exit(1)
+	}
+
+	headCommit, err := repository.HeadCommit()
+	if err != nil {
+		fmt.Printf("Error reading upstream commit: %v\n", err)
+		os.Exit(1)
+	}
+
+	if headCommit == importedCommit && !force {
+		fmt.Printf("Already up to date at commit %s, nothing to do\n", shortHash(headCommit))
+		return
+	}
+
+	if importedCommit == "" {
+		fmt.Printf("No previous import found, importing commit %s\n", shortHash(headCommit))
+	} else if headCommit == importedCommit {
+		fmt.Printf("Forcing rebuild of commit %s\n", shortHash(headCommit))
+	} else {
+		printCommitRange(importedCommit, headCommit)
+	}
+
+	err = db.InitializeDatabase(db.NewOurAirportsSource(repository.DataDir()))
+	if err != nil {
+		fmt.Printf("Error initializing database: %v\n", err)
+		os.Exit(1)
+	}
+
+	after, err := db.LiveImportStatus()
+	if err != nil {
+		fmt.Printf("Error reading new import status: %v\n", err)
+		os.Exit(1)
+	}
+
+	printRecordCountDeltas(before, after)
+}
+
+// doUpdateOpenFlights rebuilds a database built by `init --from-openflights` from the same
+// airports.dat file, only if its content changed
+func doUpdateOpenFlights(before []db.ImportStatus, force bool) {
+	var imported db.ImportStatus
+	for _, status := range before {
+		if status.TableName == "airports" {
+			imported = status
+		}
+	}
+
+	if _, err := os.Stat(imported.SourceURL); imported.SourceURL == "" || err != nil {
+		fmt.Printf("Error: the OpenFlights file %s of the last import doesn't exist, rebuild the database with `init`\n", imported.SourceURL)
+		os.Exit(1)
+	}
+
+	source := db.NewOpenFlightsSource(imported.SourceURL)
+	checksum, err := db.SourceChecksum(source)
+	if err != nil {
+		fmt.Printf("Error reading OpenFlights data: %v\n", err)
+		os.Exit(1)
+	}
+
+	if checksum == imported.ContentChecksum && !force {
+		fmt.Printf("Already up to date with %s, nothing to do\n", imported.SourceURL)
+		return
+	}
+	fmt.Printf("Rebuilding the database from %s\n", imported.SourceURL)
+
+	err = db.InitializeDatabase(source)
+	if err != nil {
+		fmt.Printf("Error initializing database: %v\n", err)
+		os.Exit(1)
+	}
+
+	after, err := db.LiveImportStatus()
+	if err != nil {
+		fmt.Printf("Error reading new import status: %v\n", err)
+		os.Exit(1)
+	}
+
+	printRecordCountDeltas(before, after)
+}
+
+// importedCommitHash returns the commit the tables were last imported from, "" if unknown
+func importedCommitHash(statuses []db.ImportStatus) string {
+	for _, status := range statuses {
+		if status.GitCommitHash != "" {
+			return status.GitCommitHash
+		}
+	}
+	return ""
+}
+
+// printCommitRange prints the upstream commits between the imported and the new commit
+func printCommitRange(from string, to string) {
+	fmt.Printf("Upstream changed: %s..%s\n", shortHash(from), shortHash(to))
+
+	commits, err := repository.CommitsBetween(from, to)
+	if err != nil || len(commits) == 0 {
+		// Shallow clones may not hold the previous commit
+		return
+	}
+
+	fmt.Printf("%d new commit(s):\n", len(commits))
+	for i, c := range commits {
+		if i == maxListedCommits {
+			fmt.Printf("  ... and %d more\n", len(commits)-maxListedCommits)
+			break
+		}
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		fmt.Printf("  %s %s %s\n", shortHash(c.Hash.String()), c.Author.When.Format("2006-01-02"), subject)
+	}
+}
+
+// printRecordCountDeltas prints the number of records per table before and after the import
+func printRecordCountDeltas(before []db.ImportStatus, after []db.ImportStatus) {
+	previous := make(map[string]int, len(before))
+	for _, status := range before {
+		previous[status.TableName] = status.RecordCount
+	}
+
+	fmt.Println("Record counts:")
+	for _, status := range after {
+		fmt.Printf("  %-12s %8d (%+d)\n", status.TableName, status.RecordCount, status.RecordCount-previous[status.TableName])
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
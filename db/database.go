@@ -13,45 +13,188 @@ import (
 	"github.com/spf13/viper"
 )
 
-// InitializeDatabase creates the database and imports airport data
-func InitializeDatabase() error {
+// DatabasePath returns the path of the live database served by `ask serve`
+func DatabasePath() string {
+	repoDir := viper.GetString("repository")
+	return filepath.Join(repoDir, viper.GetString("db"), "ask.db")
+}
+
+// InitializeDatabase builds a new database in a temporary file, imports the data
+// of the given source, validates it and then atomically replaces the live database with it.
+// The live database is left untouched if anything fails, apart from the failed run
+// being appended to its import history.
+func InitializeDatabase(source DataSource) error {
+	run := newImportRun()
+
 	dbPath, err := createDatabase()
 	if err != nil {
-		return fmt.Errorf("failed to create database: %w", err)
+		err = fmt.Errorf("failed to create database: %w", err)
+	} else {
+		err = buildDatabase(dbPath, source, run)
+		if err != nil {
+			os.Remove(dbPath)
+		}
+	}
+
+	if err != nil {
+		run.fail(err)
+		if recordErr := appendToLiveHistory(run); recordErr != nil {
+			fmt.Printf("Warning: failed to record the failed import in the history: %v\n", recordErr)
+		}
+		return err
+	}
+
+	fmt.Printf("Database installed at: %s\n", DatabasePath())
+	fmt.Println("Database initialized successfully!")
+	return nil
+}
+
+// buildDatabase imports and validates the data into the new database at dbPath, merges the overlays,
+// resolves the airport timezones, checks its quality, builds the search and spatial indexes, records the run
+// in its history, keeps a snapshot of it and installs it as the live database
+func buildDatabase(dbPath string, source DataSource, run *ImportRun) error {
+	err := importAll(dbPath, source, run)
+	if err != nil {
+		return err
 	}
 
-	err = ImportAirportsCSV(dbPath)
+	err = validateDatabase(dbPath, run.RowCounts)
 	if err != nil {
-		return fmt.Errorf("failed to import airports data: %w", err)
+		return fmt.Errorf("database validation failed: %w", err)
 	}
 
-	err = ImportCountriesCSV(dbPath)
+	err = applyOverlays(dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to import countries data: %w", err)
+		return err
 	}
 
-	fmt.Println("Database initialized successfully!")
+	err = assignTimezones(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve airport timezones: %w", err)
+	}
+
+	err = checkDataQuality(dbPath, run)
+	if err != nil {
+		return err
+	}
+
+	err = buildSearchIndex(dbPath)
+	if err != nil {
+		return err
+	}
+
+	err = buildSpatialIndex(dbPath)
+	if err != nil {
+		return err
+	}
+
+	err = recordSuccessfulRun(dbPath, run)
+	if err != nil {
+		return fmt.Errorf("failed to record import history: %w", err)
+	}
+
+	snapshotID, err := takeSnapshot(dbPath, run.source)
+	if err != nil {
+		return err
+	}
+
+	// rename(2) is atomic: readers see either the old or the new database, never a partial one
+	err = os.Rename(dbPath, DatabasePath())
+	if err != nil {
+		os.Remove(filepath.Join(SnapshotDir(), snapshotID+".db"))
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	fmt.Printf("Database snapshot: %s\n", snapshotID)
+
+	if err := recordLiveSnapshot(snapshotID); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	removed, err := PruneSnapshots(SnapshotsToKeep())
+	if err != nil {
+		fmt.Printf("Warning: failed to prune database snapshots: %v\n", err)
+	}
+	for _, id := range removed {
+		fmt.Printf("Pruned database snapshot: %s\n", id)
+	}
+
+	return nil
+}
+
+// importAll imports the data of the source into the database at dbPath
+func importAll(dbPath string, source DataSource, run *ImportRun) error {
+	info, err := source.identify()
+	if err != nil {
+		return fmt.Errorf("failed to identify the data source: %w", err)
+	}
+	run.setSource(info)
+
+	counts, err := source.importInto(dbPath, info)
+	if err != nil {
+		return err
+	}
+	run.RowCounts = counts
+
 	return nil
 }
 
-// createDatabase creates the SQLite database and airports table
+// validateDatabase checks that every imported table holds data,
+// and as many rows as recorded in import_status
+func validateDatabase(dbPath string, recordCounts map[string]int) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	for _, schema := range importedSchemas {
+		if _, imported := recordCounts[schema.table]; !imported {
+			continue
+		}
+
+		var rowCount int
+		err := db.QueryRow("SELECT COUNT(*) FROM " + schema.table).Scan(&rowCount)
+		if err != nil {
+			return fmt.Errorf("failed to count %s rows: %w", schema.table, err)
+		}
+
+		if rowCount == 0 {
+			return fmt.Errorf("table %s is empty", schema.table)
+		}
+
+		var recordCount int
+		err = db.QueryRow("SELECT record_count FROM import_status WHERE table_name = ?", schema.table).Scan(&recordCount)
+		if err != nil {
+			return fmt.Errorf("failed to read %s import status: %w", schema.table, err)
+		}
+
+		if rowCount != recordCount {
+			return fmt.Errorf("table %s has %d rows but %d records were imported", schema.table, rowCount, recordCount)
+		}
+	}
+
+	return nil
+}
+
+// createDatabase creates a new SQLite database next to the live one and migrates it to the latest schema.
+// It returns the path of the new database.
 func createDatabase() (string, error) {
-	repoDir := viper.GetString("repository")
-	dbDir := filepath.Join(repoDir, viper.GetString("db"))
+	livePath := DatabasePath()
 
 	// Ensure db directory exists
-	err := os.MkdirAll(dbDir, os.ModePerm)
+	err := os.MkdirAll(filepath.Dir(livePath), os.ModePerm)
 	if err != nil {
 		return "", fmt.Errorf("failed to create db directory: %w", err)
 	}
 
-	dbPath := filepath.Join(dbDir, "ask.db")
+	// Build in the same directory so that the final rename stays on the same filesystem
+	dbPath := livePath + ".tmp"
 
-	// Remove existing database file to ensure clean recreation
+	// Remove any leftover from an interrupted import to ensure clean recreation
 	if _, err := os.Stat(dbPath); err == nil {
 		err = os.Remove(dbPath)
 		if err != nil {
-			return "", fmt.Errorf("failed to remove existing database: %w", err)
+			return "", fmt.Errorf("failed to remove stale temporary database: %w", err)
 		}
 	}
 
@@ -61,63 +204,12 @@ func createDatabase() (string, error) {
 	}
 	defer db.Close()
 
-	// Create airports table
-	createAirportsTableSQL := `CREATE TABLE IF NOT EXISTS airports (
-		id INTEGER,
-		ident TEXT,
-		type TEXT,
-		name TEXT,
-		latitude_deg REAL,
-		longitude_deg REAL,
-		elevation_ft INTEGER,
-		continent TEXT,
-		iso_country TEXT,
-		iso_region TEXT,
-		municipality TEXT,
-		scheduled_service TEXT,
-		icao_code TEXT,
-		iata_code TEXT,
-		gps_code TEXT,
-		local_code TEXT,
-		home_link TEXT,
-		wikipedia_link TEXT,
-		keywords TEXT
-	);`
-
-	_, err = db.Exec(createAirportsTableSQL)
-	if err != nil {
-		return "", fmt.Errorf("failed to create airports table: %w", err)
-	}
-
-	// Create countries table
-	createCountriesTableSQL := `CREATE TABLE IF NOT EXISTS countries (
-		id INTEGER,
-		code TEXT,
-		name TEXT,
-		continent TEXT,
-		wikipedia_link TEXT,
-		keywords TEXT
-	);`
-
-	_, err = db.Exec(createCountriesTableSQL)
-	if err != nil {
-		return "", fmt.Errorf("failed to create countries table: %w", err)
-	}
-
-	// Create import_status table to track git commit information
-	createImportStatusTableSQL := `CREATE TABLE IF NOT EXISTS import_status (
-		table_name TEXT PRIMARY KEY,
-		last_import_date TEXT,
-		git_commit_hash TEXT,
-		git_commit_date TEXT,
-		record_count INTEGER
-	);`
-
-	_, err = db.Exec(createImportStatusTableSQL)
-	if err != nil {
-		return "", fmt.Errorf("failed to create import_status table: %w", err)
-	}
-
-	fmt.Printf("Database created at: %s\n", dbPath)
+	// Create the tables
+	err = Migrate(db)
+	if err != nil {
+		return "", fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	fmt.Printf("Building database at: %s\n", dbPath)
 	return dbPath, nil
 }
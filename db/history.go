@@ -0,0 +1,400 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	ImportSucceeded  = "success"
+	ImportFailed     = "failed"
+	ImportRolledBack = "rollback"
+
+	// recordCountDropWarning is the relative drop of a table's record count,
+	// compared to the previous import, above which a warning is recorded
+	recordCountDropWarning = 0.10
+)
+
+// importRunColumns lists the import_runs columns, in the order used by insertRun and scanImportRun
+const importRunColumns = `started_at, finished_at, duration_ms, git_commit_hash, content_checksum,
+	source_url, pinned_ref, row_counts, warnings, airports_added, airports_removed, outcome, error`
+
+// ImportRun is a single `ask init` or `ask update` run, as recorded in the import_runs history
+type ImportRun struct {
+	ID              int64          `json:"id"`
+	StartedAt       string         `json:"started_at"`
+	FinishedAt      string         `json:"finished_at"`
+	DurationMs      int64          `json:"duration_ms"`
+	GitCommitHash   string         `json:"git_commit_hash,omitempty"`
+	ContentChecksum string         `json:"content_checksum,omitempty"`
+	SourceURL       string         `json:"source_url,omitempty"`
+	PinnedRef       string         `json:"pinned_ref,omitempty"`
+	RowCounts       map[string]int `json:"row_counts"`
+	Warnings        []string       `json:"warnings"`
+	AirportsAdded   []string       `json:"airports_added"`
+	AirportsRemoved []string       `json:"airports_removed"`
+	Outcome         string         `json:"outcome"`
+	Error           string         `json:"error,omitempty"`
+
+	started time.Time
+	source  sourceInfo
+}
+
+func newImportRun() *ImportRun {
+	now := time.Now()
+	return &ImportRun{
+		StartedAt:       now.Format(time.RFC3339),
+		RowCounts:       map[string]int{},
+		Warnings:        []string{},
+		AirportsAdded:   []string{},
+		AirportsRemoved: []string{},
+		started:         now,
+	}
+}
+
+func (r *ImportRun) setSource(source sourceInfo) {
+	r.source = source
+	r.GitCommitHash = source.commitHash
+	r.ContentChecksum = source.checksum
+	r.SourceURL = source.url
+	r.PinnedRef = source.pinnedRef
+}
+
+func (r *ImportRun) finish(outcome string) {
+	now := time.Now()
+	r.FinishedAt = now.Format(time.RFC3339)
+	r.DurationMs = now.Sub(r.started).Milliseconds()
+	r.Outcome = outcome
+}
+
+func (r *ImportRun) fail(err error) {
+	r.finish(ImportFailed)
+	r.Error = err.Error()
+}
+
+// recordSuccessfulRun carries the history of the live database over to the new database
+// at dbPath, compares both to find the added and removed airports, and appends the run
+func recordSuccessfulRun(dbPath string, run *ImportRun) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	// ATTACH is per connection: keep every statement on the same one
+	db.SetMaxOpenConns(1)
+
+	livePath := DatabasePath()
+	if _, err := os.Stat(livePath); err == nil {
+		_, err = db.Exec("ATTACH DATABASE ? AS previous", livePath)
+		if err != nil {
+			return fmt.Errorf("failed to attach the live database: %w", err)
+		}
+
+		err = carryOverHistory(db, run)
+		db.Exec("DETACH DATABASE previous")
+		if err != nil {
+			return err
+		}
+	}
+
+	run.finish(ImportSucceeded)
+	return insertRun(db, run)
+}
+
+// recordRollback carries the history of the live database over to the snapshot restored at dbPath,
+// so that the runs recorded after the snapshot was taken are kept, and appends the rollback as a run
+func recordRollback(dbPath string, snapshotID string) error {
+	run := newImportRun()
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	// ATTACH is per connection: keep every statement on the same one
+	db.SetMaxOpenConns(1)
+
+	// The snapshot may have been taken before the latest migrations
+	if err := Migrate(db); err != nil {
+		return err
+	}
+
+	source, err := importedSource(db)
+	if err != nil {
+		return err
+	}
+	run.setSource(source)
+
+	run.RowCounts, err = queryCounts(db, "SELECT table_name, record_count FROM main.import_status")
+	if err != nil {
+		return fmt.Errorf("failed to read the import status: %w", err)
+	}
+	run.Warnings = append(run.Warnings, "rolled back to snapshot "+snapshotID)
+
+	livePath := DatabasePath()
+	if _, err := os.Stat(livePath); err == nil {
+		_, err = db.Exec("ATTACH DATABASE ? AS previous", livePath)
+		if err != nil {
+			return fmt.Errorf("failed to attach the live database: %w", err)
+		}
+
+		// The live history holds the runs of the snapshot, followed by the ones recorded since
+		if hasTable(db, "previous", "import_runs") {
+			_, err = db.Exec("DELETE FROM main.import_runs")
+		}
+		if err == nil {
+			err = carryOverHistory(db, run)
+		}
+		db.Exec("DETACH DATABASE previous")
+		if err != nil {
+			return err
+		}
+	}
+
+	run.finish(ImportRolledBack)
+	return insertRun(db, run)
+}
+
+// importedSource returns the source the airports of the database were imported from
+func importedSource(db *sql.DB) (sourceInfo, error) {
+	var source sourceInfo
+	err := db.QueryRow(`SELECT COALESCE(git_commit_hash, ''), COALESCE(git_commit_date, ''), COALESCE(content_checksum, ''),
+		COALESCE(source_url, ''), COALESCE(pinned_ref, '') FROM import_status WHERE table_name = 'airports'`).
+		Scan(&source.commitHash, &source.commitDate, &source.checksum, &source.url, &source.pinnedRef)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return source, fmt.Errorf("failed to read the import status: %w", err)
+	}
+	return source, nil
+}
+
+// carryOverHistory copies the import history of the attached previous database,
+// and fills the airport changes and warnings of the run by comparing both databases
+func carryOverHistory(db *sql.DB, run *ImportRun) error {
+	if !hasTable(db, "previous", "import_runs") {
+		return nil
+	}
+
+	_, err := db.Exec(`INSERT INTO main.import_runs (id, ` + importRunColumns + `)
+		SELECT id, ` + importRunColumns + ` FROM previous.import_runs ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to copy the import history: %w", err)
+	}
+
+	run.AirportsAdded, err = queryStrings(db, `SELECT ident FROM main.airports
+		EXCEPT SELECT ident FROM previous.airports ORDER BY ident`)
+	if err != nil {
+		return fmt.Errorf("failed to compare airports: %w", err)
+	}
+
+	run.AirportsRemoved, err = queryStrings(db, `SELECT ident FROM previous.airports
+		EXCEPT SELECT ident FROM main.airports ORDER BY ident`)
+	if err != nil {
+		return fmt.Errorf("failed to compare airports: %w", err)
+	}
+
+	previousCounts, err := queryCounts(db, "SELECT table_name, record_count FROM previous.import_status")
+	if err != nil {
+		return fmt.Errorf("failed to read the previous import status: %w", err)
+	}
+
+	for _, schema := range importedSchemas {
+		previous, current := previousCounts[schema.table], run.RowCounts[schema.table]
+		if previous > 0 && float64(previous-current) > float64(previous)*recordCountDropWarning {
+			run.Warnings = append(run.Warnings,
+				fmt.Sprintf("%s record count dropped from %d to %d", schema.table, previous, current))
+		}
+	}
+
+	return nil
+}
+
+// appendToLiveHistory appends the run to the history of the live database, if there is one
+func appendToLiveHistory(run *ImportRun) error {
+	livePath := DatabasePath()
+	if _, err := os.Stat(livePath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite3", livePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := Migrate(db); err != nil {
+		return err
+	}
+
+	return insertRun(db, run)
+}
+
+func insertRun(db *sql.DB, run *ImportRun) error {
+	rowCounts, _ := json.Marshal(run.RowCounts)
+	warnings, _ := json.Marshal(run.Warnings)
+	added, _ := json.Marshal(run.AirportsAdded)
+	removed, _ := json.Marshal(run.AirportsRemoved)
+
+	result, err := db.Exec(`INSERT INTO main.import_runs (`+importRunColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.StartedAt, run.FinishedAt, run.DurationMs, run.GitCommitHash, run.ContentChecksum,
+		run.SourceURL, run.PinnedRef, string(rowCounts), string(warnings), string(added), string(removed),
+		run.Outcome, run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to insert import run: %w", err)
+	}
+
+	run.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// ImportHistory returns the latest import runs of the database, newest first.
+// When airport is set, only the runs that added or removed that airport ident are returned.
+func ImportHistory(db *sql.DB, airport string, limit int) ([]ImportRun, error) {
+	query := "SELECT id, " + importRunColumns + " FROM import_runs"
+	var args []interface{}
+
+	if airport != "" {
+		// Idents are stored as JSON string arrays
+		quoted, _ := json.Marshal(airport)
+		query += " WHERE airports_added LIKE ? ESCAPE '\\' OR airports_removed LIKE ? ESCAPE '\\'"
+		pattern := "%" + escapeLike(string(quoted)) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import history: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []ImportRun{}
+	for rows.Next() {
+		var (
+			run                                 ImportRun
+			gitCommitHash, contentChecksum      sql.NullString
+			sourceURL, pinnedRef, runError      sql.NullString
+			rowCounts, warnings, added, removed sql.NullString
+		)
+
+		err := rows.Scan(&run.ID, &run.StartedAt, &run.FinishedAt, &run.DurationMs, &gitCommitHash, &contentChecksum,
+			&sourceURL, &pinnedRef, &rowCounts, &warnings, &added, &removed, &run.Outcome, &runError)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import run: %w", err)
+		}
+
+		run.GitCommitHash = gitCommitHash.String
+		run.ContentChecksum = contentChecksum.String
+		run.SourceURL = sourceURL.String
+		run.PinnedRef = pinnedRef.String
+		run.Error = runError.String
+		run.RowCounts = map[string]int{}
+		run.Warnings = []string{}
+		run.AirportsAdded = []string{}
+		run.AirportsRemoved = []string{}
+		json.Unmarshal([]byte(rowCounts.String), &run.RowCounts)
+		json.Unmarshal([]byte(warnings.String), &run.Warnings)
+		json.Unmarshal([]byte(added.String), &run.AirportsAdded)
+		json.Unmarshal([]byte(removed.String), &run.AirportsRemoved)
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read import history: %w", err)
+	}
+
+	return runs, nil
+}
+
+// LiveImportHistory returns the import history of the live database, see ImportHistory.
+// It returns an empty list if the database was never initialized.
+func LiveImportHistory(airport string, limit int) ([]ImportRun, error) {
+	livePath := DatabasePath()
+	if _, err := os.Stat(livePath); errors.Is(err, os.ErrNotExist) {
+		return []ImportRun{}, nil
+	}
+
+	db, err := sql.Open("sqlite3", livePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if !hasTable(db, "main", "import_runs") {
+		return []ImportRun{}, nil
+	}
+
+	return ImportHistory(db, airport, limit)
+}
+
+// hasTable tells whether the table exists in the given schema (main or an attached database)
+func hasTable(db *sql.DB, schema string, table string) bool {
+	var name string
+	err := db.QueryRow("SELECT name FROM "+schema+".sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+	return err == nil
+}
+
+func queryStrings(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value.String)
+	}
+
+	return values, rows.Err()
+}
+
+func queryCounts(db *sql.DB, query string) (map[string]int, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+		counts[name] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards of a value, using \ as the escape character
+func escapeLike(value string) string {
+	escaped := make([]rune, 0, len(value))
+	for _, r := range value {
+		if r == '%' || r == '_' || r == '\\' {
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, r)
+	}
+	return string(escaped)
+}
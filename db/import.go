@@ -4,13 +4,14 @@ Copyright © 2024 Nicolas Dufour
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
 	"encoding/csv"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
@@ -18,201 +19,155 @@ import (
 	"github.com/spf13/viper"
 )
 
-// getGitCommitInfo retrieves the latest git commit information from the data directory
-func getGitCommitInfo() (string, string, error) {
-	repoDir := viper.GetString("repository")
-	dataDir := filepath.Join(repoDir, viper.GetString("data"))
+// sourceInfo identifies the data an import was built from: the git commit when the
+// data directory is a git checkout, a checksum of the CSV files otherwise
+type sourceInfo struct {
+	commitHash string
+	commitDate string
+	checksum   string
+	url        string
+	pinnedRef  string
+}
 
+// getSourceInfo retrieves the latest git commit information from the data directory,
+// or computes a content checksum when the directory holds no git metadata
+func getSourceInfo(dataDir string) (sourceInfo, error) {
 	repo, err := git.PlainOpen(dataDir)
+	if err == git.ErrRepositoryNotExists {
+		checksum, err := contentChecksum(dataDir)
+		if err != nil {
+			return sourceInfo{}, fmt.Errorf("failed to compute content checksum: %w", err)
+		}
+		return sourceInfo{checksum: checksum}, nil
+	}
 	if err != nil {
-		return "", "", fmt.Errorf("failed to open git repository: %w", err)
+		return sourceInfo{}, fmt.Errorf("failed to open git repository: %w", err)
 	}
 
 	ref, err := repo.Head()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get HEAD reference: %w", err)
+		return sourceInfo{}, fmt.Errorf("failed to get HEAD reference: %w", err)
 	}
 
 	commit, err := repo.CommitObject(ref.Hash())
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get commit object: %w", err)
+		return sourceInfo{}, fmt.Errorf("failed to get commit object: %w", err)
 	}
 
-	return ref.Hash().String(), commit.Author.When.Format(time.RFC3339), nil
-}
-
-// updateImportStatus updates the import status table with git commit information
-func updateImportStatus(db *sql.DB, tableName string, recordCount int) error {
-	commitHash, commitDate, err := getGitCommitInfo()
-	if err != nil {
-		return fmt.Errorf("failed to get git commit info: %w", err)
+	var url string
+	if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+		url = remote.Config().URLs[0]
 	}
 
-	importDate := time.Now().Format(time.RFC3339)
-
-	query := `INSERT OR REPLACE INTO import_status 
-			  (table_name, last_import_date, git_commit_hash, git_commit_date, record_count) 
-			  VALUES (?, ?, ?, ?, ?)`
-
-	_, err = db.Exec(query, tableName, importDate, commitHash, commitDate, recordCount)
-	if err != nil {
-		return fmt.Errorf("failed to update import status: %w", err)
-	}
-
-	return nil
+	return sourceInfo{
+		commitHash: ref.Hash().String(),
+		commitDate: commit.Author.When.Format(time.RFC3339),
+		url:        url,
+		pinnedRef:  viper.GetString("source.ref"),
+	}, nil
 }
 
-// ImportAirportsCSV imports the airports.csv file into the database
-func ImportAirportsCSV(dbPath string) error {
-	repoDir := viper.GetString("repository")
-	dataDir := filepath.Join(repoDir, viper.GetString("data"))
-	csvPath := filepath.Join(dataDir, "airports.csv")
-
-	// Check if CSV file exists
-	if _, err := os.Stat(csvPath); os.IsNotExist(err) {
-		return fmt.Errorf("airports.csv not found at %s", csvPath)
-	}
-
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
-	}
-	defer db.Close()
-
-	// Open CSV file
-	file, err := os.Open(csvPath)
-	if err != nil {
-		return fmt.Errorf("failed to open CSV file: %w", err)
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-
-	// Read header row
-	header, err := reader.Read()
-	if err != nil {
-		return fmt.Errorf("failed to read CSV header: %w", err)
-	}
-
-	fmt.Printf("CSV header: %v\n", header)
-
-	// Clear existing data
-	_, err = db.Exec("DELETE FROM airports")
-	if err != nil {
-		return fmt.Errorf("failed to clear existing data: %w", err)
-	}
-
-	// Prepare insert statement
-	placeholders := strings.Repeat("?,", len(header)-1) + "?"
-	insertSQL := fmt.Sprintf("INSERT INTO airports VALUES (%s)", placeholders)
-
-	stmt, err := db.Prepare(insertSQL)
-	if err != nil {
-		return fmt.Errorf("failed to prepare insert statement: %w", err)
-	}
-	defer stmt.Close()
-
-	// Begin transaction for better performance
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// contentChecksum returns the SHA-256 of every imported CSV file of the data directory,
+// in import order, formatted as "sha256:<hex>"
+func contentChecksum(dataDir string) (string, error) {
+	paths := make([]string, len(importedSchemas))
+	for i, schema := range importedSchemas {
+		paths[i] = filepath.Join(dataDir, schema.csvFile)
 	}
+	return filesChecksum(paths)
+}
 
-	recordCount := 0
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
+// filesChecksum returns the SHA-256 of the given files, in order, formatted as "sha256:<hex>"
+func filesChecksum(paths []string) (string, error) {
+	hash := sha256.New()
+	for _, path := range paths {
+		file, err := os.Open(path)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to read CSV record: %w", err)
-		}
-
-		// Convert record to interface slice for SQL parameters
-		args := make([]interface{}, len(record))
-		for i, v := range record {
-			args[i] = v
+			return "", err
 		}
 
-		_, err = tx.Stmt(stmt).Exec(args...)
+		// Include the file name so that identical contents in different files hash differently
+		io.WriteString(hash, filepath.Base(path)+"\x00")
+		_, err = io.Copy(hash, file)
+		file.Close()
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to insert record: %w", err)
+			return "", err
 		}
-
-		recordCount++
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	// Update import status
-	err = updateImportStatus(db, "airports", recordCount)
+// updateImportStatus updates the import status table with the source information
+func updateImportStatus(db *sql.DB, tableName string, recordCount int, source sourceInfo) error {
+	importDate := time.Now().Format(time.RFC3339)
+
+	query := `INSERT OR REPLACE INTO import_status 
+			  (table_name, last_import_date, git_commit_hash, git_commit_date, record_count, content_checksum, source_url, pinned_ref) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := db.Exec(query, tableName, importDate, source.commitHash, source.commitDate, recordCount, source.checksum, source.url, source.pinnedRef)
 	if err != nil {
 		return fmt.Errorf("failed to update import status: %w", err)
 	}
 
-	fmt.Printf("Successfully imported %d airport records\n", recordCount)
 	return nil
 }
 
-// ImportCountriesCSV imports the countries.csv file into the database
-func ImportCountriesCSV(dbPath string) error {
-	repoDir := viper.GetString("repository")
-	dataDir := filepath.Join(repoDir, viper.GetString("data"))
-	csvPath := filepath.Join(dataDir, "countries.csv")
+// importCSV imports the CSV file declared by schema from dataDir into its table.
+// The CSV header must match the declared columns, and each field is converted to its declared type.
+// The table is cleared first and the import status is updated on success.
+// It returns the number of imported records.
+func importCSV(dbPath string, dataDir string, schema tableSchema, source sourceInfo) (int, error) {
+	csvPath := filepath.Join(dataDir, schema.csvFile)
 
 	// Check if CSV file exists
 	if _, err := os.Stat(csvPath); os.IsNotExist(err) {
-		return fmt.Errorf("countries.csv not found at %s", csvPath)
+		return 0, fmt.Errorf("%s not found at %s", schema.csvFile, csvPath)
 	}
 
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return 0, fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
 	// Open CSV file
 	file, err := os.Open(csvPath)
 	if err != nil {
-		return fmt.Errorf("failed to open CSV file: %w", err)
+		return 0, fmt.Errorf("failed to open CSV file: %w", err)
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 
-	// Read header row
+	// Read header row and make sure upstream did not change the layout
 	header, err := reader.Read()
 	if err != nil {
-		return fmt.Errorf("failed to read CSV header: %w", err)
+		return 0, fmt.Errorf("failed to read CSV header: %w", err)
 	}
 
-	fmt.Printf("CSV header: %v\n", header)
+	if err := schema.checkHeader(header); err != nil {
+		return 0, err
+	}
 
 	// Clear existing data
-	_, err = db.Exec("DELETE FROM countries")
+	_, err = db.Exec("DELETE FROM " + schema.table)
 	if err != nil {
-		return fmt.Errorf("failed to clear existing data: %w", err)
+		return 0, fmt.Errorf("failed to clear existing data: %w", err)
 	}
 
 	// Prepare insert statement
-	placeholders := strings.Repeat("?,", len(header)-1) + "?"
-	insertSQL := fmt.Sprintf("INSERT INTO countries VALUES (%s)", placeholders)
-
-	stmt, err := db.Prepare(insertSQL)
+	stmt, err := db.Prepare(schema.insertSQL())
 	if err != nil {
-		return fmt.Errorf("failed to prepare insert statement: %w", err)
+		return 0, fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	// Begin transaction for better performance
 	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
 	recordCount := 0
@@ -223,19 +178,20 @@ func ImportCountriesCSV(dbPath string) error {
 		}
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to read CSV record: %w", err)
+			return 0, fmt.Errorf("failed to read CSV record: %w", err)
 		}
 
-		// Convert record to interface slice for SQL parameters
-		args := make([]interface{}, len(record))
-		for i, v := range record {
-			args[i] = v
+		args, err := schema.convertRecord(record)
+		if err != nil {
+			tx.Rollback()
+			line, _ := reader.FieldPos(0)
+			return 0, fmt.Errorf("%s line %d: %w", schema.csvFile, line, err)
 		}
 
 		_, err = tx.Stmt(stmt).Exec(args...)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to insert record: %w", err)
+			return 0, fmt.Errorf("failed to insert record: %w", err)
 		}
 
 		recordCount++
@@ -243,15 +199,14 @@ func ImportCountriesCSV(dbPath string) error {
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Update import status
-	err = updateImportStatus(db, "countries", recordCount)
+	err = updateImportStatus(db, schema.table, recordCount, source)
 	if err != nil {
-		return fmt.Errorf("failed to update import status: %w", err)
+		return 0, fmt.Errorf("failed to update import status: %w", err)
 	}
 
-	fmt.Printf("Successfully imported %d country records\n", recordCount)
-	return nil
+	return recordCount, nil
 }
@@ -0,0 +1,348 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a single, ordered schema change.
+// Either or both of statements and apply can be set; statements run first.
+type migration struct {
+	version     int
+	description string
+	statements  string
+	apply       func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change, in order. Versions must be strictly increasing,
+// and a migration must never be modified once released: add a new one instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create base tables",
+		statements: `
+			CREATE TABLE IF NOT EXISTS airports (
+				id INTEGER,
+				ident TEXT,
+				type TEXT,
+				name TEXT,
+				latitude_deg REAL,
+				longitude_deg REAL,
+				elevation_ft INTEGER,
+				continent TEXT,
+				iso_country TEXT,
+				iso_region TEXT,
+				municipality TEXT,
+				scheduled_service TEXT,
+				icao_code TEXT,
+				iata_code TEXT,
+				gps_code TEXT,
+				local_code TEXT,
+				home_link TEXT,
+				wikipedia_link TEXT,
+				keywords TEXT
+			);
+
+			CREATE TABLE IF NOT EXISTS countries (
+				id INTEGER,
+				code TEXT,
+				name TEXT,
+				continent TEXT,
+				wikipedia_link TEXT,
+				keywords TEXT
+			);
+
+			CREATE TABLE IF NOT EXISTS regions (
+				id INTEGER,
+				code TEXT,
+				local_code TEXT,
+				name TEXT,
+				continent TEXT,
+				iso_country TEXT,
+				wikipedia_link TEXT,
+				keywords TEXT
+			);
+
+			CREATE TABLE IF NOT EXISTS runways (
+				id INTEGER,
+				airport_ref INTEGER,
+				airport_ident TEXT,
+				length_ft INTEGER,
+				width_ft INTEGER,
+				surface TEXT,
+				lighted INTEGER,
+				closed INTEGER,
+				le_ident TEXT,
+				le_latitude_deg REAL,
+				le_longitude_deg REAL,
+				le_elevation_ft INTEGER,
+				le_heading_degT REAL,
+				le_displaced_threshold_ft INTEGER,
+				he_ident TEXT,
+				he_latitude_deg REAL,
+				he_longitude_deg REAL,
+				he_elevation_ft INTEGER,
+				he_heading_degT REAL,
+				he_displaced_threshold_ft INTEGER
+			);
+
+			CREATE TABLE IF NOT EXISTS navaids (
+				id INTEGER,
+				filename TEXT,
+				ident TEXT,
+				name TEXT,
+				type TEXT,
+				frequency_khz INTEGER,
+				latitude_deg REAL,
+				longitude_deg REAL,
+				elevation_ft INTEGER,
+				iso_country TEXT,
+				dme_frequency_khz INTEGER,
+				dme_channel TEXT,
+				dme_latitude_deg REAL,
+				dme_longitude_deg REAL,
+				dme_elevation_ft INTEGER,
+				slaved_variation_deg REAL,
+				magnetic_variation_deg REAL,
+				usageType TEXT,
+				power TEXT,
+				associated_airport TEXT
+			);
+
+			CREATE TABLE IF NOT EXISTS frequencies (
+				id INTEGER,
+				airport_ref INTEGER,
+				airport_ident TEXT,
+				type TEXT,
+				description TEXT,
+				frequency_mhz REAL
+			);
+
+			CREATE TABLE IF NOT EXISTS import_status (
+				table_name TEXT PRIMARY KEY,
+				last_import_date TEXT,
+				git_commit_hash TEXT,
+				git_commit_date TEXT,
+				record_count INTEGER
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_regions_code ON regions (code);`,
+	},
+	{
+		version:     2,
+		description: "index airport references",
+		statements: `
+			CREATE INDEX IF NOT EXISTS idx_runways_airport_ref ON runways (airport_ref);
+			CREATE INDEX IF NOT EXISTS idx_frequencies_airport_ref ON frequencies (airport_ref);`,
+	},
+	{
+		version:     3,
+		description: "record content checksums of imports without git metadata",
+		statements:  `ALTER TABLE import_status ADD COLUMN content_checksum TEXT;`,
+	},
+	{
+		version:     4,
+		description: "record the data source URL and pinned ref of imports",
+		statements: `
+			ALTER TABLE import_status ADD COLUMN source_url TEXT;
+			ALTER TABLE import_status ADD COLUMN pinned_ref TEXT;`,
+	},
+	{
+		version:     5,
+		description: "keep an append-only history of import runs",
+		statements: `
+			CREATE TABLE IF NOT EXISTS import_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				started_at TEXT NOT NULL,
+				finished_at TEXT NOT NULL,
+				duration_ms INTEGER NOT NULL,
+				git_commit_hash TEXT,
+				content_checksum TEXT,
+				source_url TEXT,
+				pinned_ref TEXT,
+				row_counts TEXT,
+				warnings TEXT,
+				airports_added TEXT,
+				airports_removed TEXT,
+				outcome TEXT NOT NULL,
+				error TEXT
+			);`,
+	},
+	{
+		version:     6,
+		description: "store the data quality issues found by the import",
+		statements: `
+			CREATE TABLE IF NOT EXISTS import_issues (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				check_name TEXT NOT NULL,
+				table_name TEXT NOT NULL,
+				ident TEXT,
+				message TEXT NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_import_issues_check ON import_issues(check_name);`,
+	},
+	{
+		version:     7,
+		description: "index airport ids",
+		statements: `
+			CREATE INDEX IF NOT EXISTS idx_airports_id ON airports(id);`,
+	},
+	{
+		version:     8,
+		description: "record the source of each airport",
+		statements: `
+			ALTER TABLE airports ADD COLUMN source TEXT NOT NULL DEFAULT 'ourairports';`,
+	},
+	{
+		version:     9,
+		description: "store the timezone of each airport",
+		statements: `
+			ALTER TABLE airports ADD COLUMN timezone TEXT;
+			CREATE INDEX IF NOT EXISTS idx_airports_timezone ON airports(timezone);`,
+	},
+	{
+		version:     10,
+		description: "index airport codes",
+		statements: `
+			CREATE INDEX IF NOT EXISTS idx_airports_icao_code ON airports(icao_code COLLATE NOCASE);
+			CREATE INDEX IF NOT EXISTS idx_airports_iata_code ON airports(iata_code COLLATE NOCASE);
+			CREATE INDEX IF NOT EXISTS idx_airports_ident ON airports(ident COLLATE NOCASE);
+			CREATE INDEX IF NOT EXISTS idx_airports_gps_code ON airports(gps_code COLLATE NOCASE);
+			CREATE INDEX IF NOT EXISTS idx_airports_local_code ON airports(local_code COLLATE NOCASE);`,
+	},
+	{
+		// Databases imported before the typed import stored blank fields as empty strings
+		version:     11,
+		description: "store blank numeric fields of airports and countries as NULL",
+		statements: `
+			UPDATE airports SET id = NULL WHERE TRIM(id) = '';
+			UPDATE airports SET latitude_deg = NULL WHERE TRIM(latitude_deg) = '';
+			UPDATE airports SET longitude_deg = NULL WHERE TRIM(longitude_deg) = '';
+			UPDATE airports SET elevation_ft = NULL WHERE TRIM(elevation_ft) = '';
+			UPDATE countries SET id = NULL WHERE TRIM(id) = '';`,
+	},
+}
+
+// DatabaseTooNewError is returned when the database was migrated by a newer
+// binary than the running one, which cannot safely use it.
+type DatabaseTooNewError struct {
+	Version   int
+	Supported int
+}
+
+func (e *DatabaseTooNewError) Error() string {
+	return fmt.Sprintf("database schema version %d is newer than the latest version supported by this binary (%d), please upgrade ask", e.Version, e.Supported)
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates databases to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the current schema version of the database, 0 if it was never migrated
+func SchemaVersion(db *sql.DB) (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at TEXT
+	);`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// CheckSchemaVersion fails with a DatabaseTooNewError if the database is newer than this binary.
+// Unlike SchemaVersion and Migrate, it never writes to the database.
+func CheckSchemaVersion(db *sql.DB) error {
+	var tables int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if tables == 0 {
+		// Never migrated
+		return nil
+	}
+
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if latest := LatestSchemaVersion(); version > latest {
+		return &DatabaseTooNewError{Version: version, Supported: latest}
+	}
+	return nil
+}
+
+// Migrate applies every pending migration to the database, each in its own transaction.
+// It fails with a DatabaseTooNewError if the database is newer than this binary.
+func Migrate(db *sql.DB) error {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return &DatabaseTooNewError{Version: current, Supported: latest}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.description, err)
+		}
+
+		fmt.Printf("Applied database migration %d: %s\n", m.version, m.description)
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration and records it in schema_version
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if m.statements != "" {
+		if _, err := tx.Exec(m.statements); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if m.apply != nil {
+		if err := m.apply(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+		m.version, m.description, time.Now().Format(time.RFC3339))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
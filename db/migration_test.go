@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// baselineSchema is the schema created, without any schema_version table, by the releases before the migrations
+const baselineSchema = `
+	CREATE TABLE airports (
+		id INTEGER, ident TEXT, type TEXT, name TEXT, latitude_deg REAL, longitude_deg REAL, elevation_ft INTEGER,
+		continent TEXT, iso_country TEXT, iso_region TEXT, municipality TEXT, scheduled_service TEXT, icao_code TEXT,
+		iata_code TEXT, gps_code TEXT, local_code TEXT, home_link TEXT, wikipedia_link TEXT, keywords TEXT
+	);
+	CREATE TABLE countries (id INTEGER, code TEXT, name TEXT, continent TEXT, wikipedia_link TEXT, keywords TEXT);
+	CREATE TABLE import_status (
+		table_name TEXT PRIMARY KEY, last_import_date TEXT, git_commit_hash TEXT, git_commit_date TEXT, record_count INTEGER
+	);`
+
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "ask.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+
+	// The baseline importer inserted every field as text, blank ones included
+	_, err := db.Exec(baselineSchema + `
+		INSERT INTO airports (id, ident, name, latitude_deg, longitude_deg, elevation_ft)
+			VALUES ('1', 'EGLL', 'Heathrow', '51.4706', '-0.4619', ''), ('2', 'LFPG', 'Charles de Gaulle', '49.0097', '2.5479', '392');
+		INSERT INTO countries (id, code, name) VALUES ('', 'XX', 'Nowhere');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT typeof(elevation_ft) FROM airports WHERE ident = 'EGLL'", want: "null"},
+		{query: "SELECT typeof(elevation_ft) FROM airports WHERE ident = 'LFPG'", want: "integer"},
+		{query: "SELECT typeof(latitude_deg) FROM airports WHERE ident = 'EGLL'", want: "real"},
+		{query: "SELECT typeof(id) FROM countries WHERE code = 'XX'", want: "null"},
+		{query: "SELECT source FROM airports WHERE ident = 'EGLL'", want: "ourairports"},
+	}
+	for _, tt := range tests {
+		var got string
+		if err := db.QueryRow(tt.query).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, want %d", version, LatestSchemaVersion())
+	}
+}
+
+func TestMigrateDatabaseTooNew(t *testing.T) {
+	db := openTestDatabase(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_version (version) VALUES (?)", LatestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+
+	var tooNew *DatabaseTooNewError
+	if err := Migrate(db); !errors.As(err, &tooNew) {
+		t.Errorf("Migrate() error = %v, want a DatabaseTooNewError", err)
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	db := openTestDatabase(t)
+
+	// A database never migrated is accepted and left untouched
+	if err := CheckSchemaVersion(db); err != nil {
+		t.Fatalf("CheckSchemaVersion() error = %v", err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("CheckSchemaVersion() created %d tables, want none", tables)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchemaVersion(db); err != nil {
+		t.Errorf("CheckSchemaVersion() error = %v on a migrated database", err)
+	}
+
+	if _, err := db.Exec("INSERT INTO schema_version (version) VALUES (?)", LatestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	var tooNew *DatabaseTooNewError
+	if err := CheckSchemaVersion(db); !errors.As(err, &tooNew) {
+		t.Errorf("CheckSchemaVersion() error = %v, want a DatabaseTooNewError", err)
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openFlightsNull is the field value OpenFlights uses for missing values
+const openFlightsNull = `\N`
+
+// openFlightsCountriesFile is looked up next to airports.dat to map country names to ISO codes
+const openFlightsCountriesFile = "countries.dat"
+
+// Fields of an OpenFlights airports.dat record, which has no header.
+// Older files stop after the timezone database field.
+const (
+	ofAirportID = iota
+	ofName
+	ofCity
+	ofCountry
+	ofIATA
+	ofICAO
+	ofLatitude
+	ofLongitude
+	ofAltitude
+	ofTimezoneOffset
+	ofDST
+	ofTimezone
+	ofMinFields
+)
+
+// Fields of an OpenFlights countries.dat record
+const (
+	ofCountryName = iota
+	ofCountryISOCode
+	ofCountryDAFIFCode
+)
+
+// openFlightsSource is the OpenFlights dataset: an airports.dat file, and optionally the
+// countries.dat file next to it. OpenFlights ids are kept as airport ids.
+type openFlightsSource struct {
+	airportsPath string
+}
+
+// NewOpenFlightsSource returns the OpenFlights dataset of the airports.dat file at airportsPath
+func NewOpenFlightsSource(airportsPath string) DataSource {
+	return &openFlightsSource{airportsPath: airportsPath}
+}
+
+func (s *openFlightsSource) Name() string {
+	return SourceOpenFlights
+}
+
+func (s *openFlightsSource) countriesPath() string {
+	return filepath.Join(filepath.Dir(s.airportsPath), openFlightsCountriesFile)
+}
+
+func (s *openFlightsSource) hasCountries() bool {
+	_, err := os.Stat(s.countriesPath())
+	return err == nil
+}
+
+func (s *openFlightsSource) identify() (sourceInfo, error) {
+	paths := []string{s.airportsPath}
+	if s.hasCountries() {
+		paths = append(paths, s.countriesPath())
+	}
+
+	checksum, err := filesChecksum(paths)
+	if err != nil {
+		return sourceInfo{}, fmt.Errorf("failed to compute content checksum: %w", err)
+	}
+
+	url, err := filepath.Abs(s.airportsPath)
+	if err != nil {
+		return sourceInfo{}, err
+	}
+
+	return sourceInfo{checksum: checksum, url: url}, nil
+}
+
+func (s *openFlightsSource) importInto(dbPath string, source sourceInfo) (map[string]int, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	counts := map[string]int{}
+
+	// OpenFlights identifies countries by name only, countries.dat gives their ISO codes
+	countryCodes := map[string]string{}
+	if s.hasCountries() {
+		countryCodes, err = s.importCountries(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import countries data: %w", err)
+		}
+		counts["countries"] = len(countryCodes)
+
+		err = updateImportStatus(db, "countries", len(countryCodes), source)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Successfully imported %d countries records\n", len(countryCodes))
+	} else {
+		fmt.Printf("Warning: no %s next to %s, airports will have no country\n", openFlightsCountriesFile, s.airportsPath)
+	}
+
+	airportCount, err := s.importAirports(db, countryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import airports data: %w", err)
+	}
+	counts["airports"] = airportCount
+
+	err = updateImportStatus(db, "airports", airportCount, source)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Successfully imported %d airports records\n", airportCount)
+
+	return counts, nil
+}
+
+// importCountries imports countries.dat and returns the ISO code of each country name
+func (s *openFlightsSource) importCountries(db *sql.DB) (map[string]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	codes := map[string]string{}
+	err = readOpenFlightsFile(s.countriesPath(), ofCountryDAFIFCode+1, func(record []string) error {
+		name, code := openFlightsText(record[ofCountryName]), openFlightsText(record[ofCountryISOCode])
+		if name == "" || code == "" {
+			// A few territories have no ISO code
+			return nil
+		}
+
+		codes[name] = code
+		_, err := tx.Exec("INSERT INTO countries (id, code, name) VALUES (?, ?, ?)", len(codes), code, name)
+		return err
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return codes, nil
+}
+
+// importAirports imports airports.dat, mapping its fields to the ourairports columns.
+// OpenFlights has no airport size: airports with an IATA code, hence usually scheduled
+// traffic, are imported as medium airports and the others as small airports.
+func (s *openFlightsSource) importAirports(db *sql.DB, countryCodes map[string]string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	count := 0
+	err = readOpenFlightsFile(s.airportsPath, ofMinFields, func(record []string) error {
+		values := map[string]interface{}{}
+		for name, field := range map[string]int{
+			"id":            ofAirportID,
+			"latitude_deg":  ofLatitude,
+			"longitude_deg": ofLongitude,
+			"elevation_ft":  ofAltitude,
+		} {
+			c, _ := airportsSchema.column(name)
+			value, err := c.convert(openFlightsField(record[field]))
+			if err != nil {
+				return err
+			}
+			values[name] = value
+		}
+		if values["id"] == nil {
+			return errors.New("missing airport id")
+		}
+
+		icao, iata := openFlightsValue(record[ofICAO]), openFlightsValue(record[ofIATA])
+
+		ident := "OF-" + openFlightsText(record[ofAirportID])
+		if icao != nil {
+			ident = openFlightsText(record[ofICAO])
+		} else if iata != nil {
+			ident = openFlightsText(record[ofIATA])
+		}
+
+		airportType := "small_airport"
+		if iata != nil {
+			airportType = "medium_airport"
+		}
+
+		var country interface{}
+		if code, ok := countryCodes[openFlightsText(record[ofCountry])]; ok {
+			country = code
+		}
+
+		_, err := tx.Exec(`INSERT INTO airports (id, ident, type, name, latitude_deg, longitude_deg, elevation_ft,
+			iso_country, municipality, icao_code, iata_code, timezone, source)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			values["id"], ident, airportType, openFlightsValue(record[ofName]),
+			values["latitude_deg"], values["longitude_deg"], values["elevation_ft"],
+			country, openFlightsValue(record[ofCity]), icao, iata, openFlightsTimezone(record[ofTimezone]), SourceOpenFlights)
+		if err != nil {
+			return fmt.Errorf("failed to insert record: %w", err)
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return count, nil
+}
+
+// readOpenFlightsFile hands every record of an OpenFlights file, which has no header, to handle
+func readOpenFlightsFile(path string, minFields int, handle func(record []string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	// Some names hold unescaped quotes
+	reader.LazyQuotes = true
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) < minFields {
+			return fmt.Errorf("%s line %d: expected at least %d fields, got %d", filepath.Base(path), line, minFields, len(record))
+		}
+
+		if err := handle(record); err != nil {
+			return fmt.Errorf("%s line %d: %w", filepath.Base(path), line, err)
+		}
+	}
+}
+
+// openFlightsTimezone returns the timezone database field as a SQL argument,
+// nil when it is not a known IANA timezone so that it gets resolved from the coordinates
+func openFlightsTimezone(field string) interface{} {
+	value := openFlightsValue(field)
+	if value == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(field); err != nil {
+		return nil
+	}
+	return value
+}
+
+// openFlightsField returns the field, or an empty string when it is the OpenFlights null value
+func openFlightsField(field string) string {
+	if field == openFlightsNull {
+		return ""
+	}
+	return field
+}
+
+// openFlightsText returns the trimmed field, or an empty string when it is the OpenFlights null value
+func openFlightsText(field string) string {
+	return strings.TrimSpace(openFlightsField(field))
+}
+
+// openFlightsValue returns the field as a SQL argument, nil when it is empty or null
+func openFlightsValue(field string) interface{} {
+	field = openFlightsText(field)
+	if field == "" {
+		return nil
+	}
+	return field
+}
@@ -0,0 +1,352 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// overlayFileNames are the overlay files looked up in the repository directory, applied in this order.
+// They live outside the data directory so that pulling the data never touches them.
+var overlayFileNames = []string{"overlay.csv", "overlay.yaml", "overlay.yml"}
+
+// overlayHiddenField is the overlay field hiding the upstream airport with the same ident
+const overlayHiddenField = "hidden"
+
+// overlayRequiredFields must be set on overlay airports that are not in the upstream data
+var overlayRequiredFields = []string{"type", "name", "latitude_deg", "longitude_deg"}
+
+// overlayRow is a single airport of an overlay file, with its fields converted to typed SQL arguments.
+// Fields left empty are not in values.
+type overlayRow struct {
+	location string
+	ident    string
+	hidden   bool
+	values   map[string]interface{}
+}
+
+// overlayYAML is the layout of a YAML overlay file
+type overlayYAML struct {
+	Airports []map[string]interface{} `yaml:"airports"`
+}
+
+// OverlayPaths returns the overlay files present in the repository directory
+func OverlayPaths() []string {
+	var paths []string
+	for _, name := range overlayFileNames {
+		path := filepath.Join(viper.GetString("repository"), name)
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// applyOverlays merges the overlay files of the repository directory into the airports
+// of the database at dbPath. An overlay airport overrides the non-empty fields of the
+// upstream airport with the same ident, is added when there is none, or hides it when
+// marked hidden. Overridden and added airports get the overlay source.
+func applyOverlays(dbPath string) error {
+	for _, path := range OverlayPaths() {
+		rows, err := readOverlay(path)
+		if err != nil {
+			return fmt.Errorf("failed to read overlay %s: %w", path, err)
+		}
+
+		err = applyOverlay(dbPath, path, rows)
+		if err != nil {
+			return fmt.Errorf("failed to apply overlay %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func applyOverlay(dbPath string, path string, rows []overlayRow) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var added, overridden, hidden int
+	var nextID int64
+	for _, row := range rows {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM airports WHERE ident = ?)", row.ident).Scan(&exists)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to look up airport %s: %w", row.ident, err)
+		}
+
+		switch {
+		case row.hidden:
+			if !exists {
+				fmt.Printf("Warning: %s: no airport %s to hide\n", row.location, row.ident)
+				continue
+			}
+			_, err = tx.Exec("DELETE FROM airports WHERE ident = ?", row.ident)
+			hidden++
+		case exists:
+			err = checkOverlayID(tx, row)
+			if err == nil {
+				err = overrideAirport(tx, row)
+			}
+			overridden++
+		default:
+			if _, ok := row.values["id"]; ok {
+				err = checkOverlayID(tx, row)
+			} else {
+				// Airports only in the overlay get negative ids, which ourairports never uses
+				err = tx.QueryRow("SELECT MIN(0, COALESCE(MIN(id), 0)) - 1 FROM airports").Scan(&nextID)
+				row.values["id"] = nextID
+			}
+			if err == nil {
+				err = addAirport(tx, row)
+			}
+			added++
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", row.location, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	fmt.Printf("Applied overlay %s: %d added, %d overridden, %d hidden\n", filepath.Base(path), added, overridden, hidden)
+	return nil
+}
+
+// checkOverlayID rejects an id given by the overlay row when another airport already has it:
+// the airport ids key the search and spatial indexes, and the runways of the airports
+func checkOverlayID(tx *sql.Tx, row overlayRow) error {
+	id, ok := row.values["id"]
+	if !ok {
+		return nil
+	}
+
+	var taken bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM airports WHERE id = ? AND ident != ?)", id, row.ident).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("failed to look up airport id %v: %w", id, err)
+	}
+	if taken {
+		return fmt.Errorf("airport %s: id %v is already used by another airport", row.ident, id)
+	}
+	return nil
+}
+
+func overrideAirport(tx *sql.Tx, row overlayRow) error {
+	assignments := []string{"source = ?"}
+	args := []interface{}{SourceOverlay}
+
+	// Moved airports get their timezone resolved again
+	_, movedLat := row.values["latitude_deg"]
+	_, movedLon := row.values["longitude_deg"]
+	if movedLat || movedLon {
+		assignments = append(assignments, "timezone = NULL")
+	}
+
+	for _, name := range sortedKeys(row.values) {
+		assignments = append(assignments, name+" = ?")
+		args = append(args, row.values[name])
+	}
+	args = append(args, row.ident)
+
+	_, err := tx.Exec("UPDATE airports SET "+strings.Join(assignments, ", ")+" WHERE ident = ?", args...)
+	if err != nil {
+		return fmt.Errorf("failed to override airport %s: %w", row.ident, err)
+	}
+	return nil
+}
+
+func addAirport(tx *sql.Tx, row overlayRow) error {
+	for _, name := range overlayRequiredFields {
+		if _, ok := row.values[name]; !ok {
+			return fmt.Errorf("new airport %s has no %s", row.ident, name)
+		}
+	}
+
+	names := []string{"ident", "source"}
+	args := []interface{}{row.ident, SourceOverlay}
+	for _, name := range sortedKeys(row.values) {
+		names = append(names, name)
+		args = append(args, row.values[name])
+	}
+
+	placeholders := strings.Repeat("?,", len(names)-1) + "?"
+	_, err := tx.Exec("INSERT INTO airports ("+strings.Join(names, ", ")+") VALUES ("+placeholders+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to add airport %s: %w", row.ident, err)
+	}
+	return nil
+}
+
+// readOverlay reads a CSV or YAML overlay file, depending on its extension
+func readOverlay(path string) ([]overlayRow, error) {
+	if filepath.Ext(path) == ".csv" {
+		return readOverlayCSV(path)
+	}
+	return readOverlayYAML(path)
+}
+
+// readOverlayCSV reads an overlay CSV file. Its header names the airports.csv columns
+// it sets, in any order, and must include ident.
+func readOverlayCSV(path string) ([]overlayRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	if !slices.Contains(header, "ident") {
+		return nil, errors.New("the header has no ident column")
+	}
+
+	var rows []overlayRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV record: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		fields := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			fields[name] = record[i]
+		}
+
+		row, err := newOverlayRow(fmt.Sprintf("%s line %d", filepath.Base(path), line), fields)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readOverlayYAML reads an overlay YAML file: a list of airports under the airports key,
+// each a mapping of airports.csv column names to values
+func readOverlayYAML(path string) ([]overlayRow, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlay overlayYAML
+	if err := yaml.Unmarshal(content, &overlay); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	rows := make([]overlayRow, 0, len(overlay.Airports))
+	for i, fields := range overlay.Airports {
+		row, err := newOverlayRow(fmt.Sprintf("%s airport %d", filepath.Base(path), i+1), fields)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// newOverlayRow validates and converts the fields of an overlay airport
+func newOverlayRow(location string, fields map[string]interface{}) (overlayRow, error) {
+	row := overlayRow{location: location, values: map[string]interface{}{}}
+
+	for name, value := range fields {
+		field := ""
+		if value != nil {
+			field = strings.TrimSpace(fmt.Sprint(value))
+		}
+
+		switch name {
+		case "ident":
+			row.ident = field
+			continue
+		case overlayHiddenField:
+			hidden, err := parseOverlayBool(field)
+			if err != nil {
+				return overlayRow{}, fmt.Errorf("%s: %w", location, err)
+			}
+			row.hidden = hidden
+			continue
+		}
+
+		c, ok := airportsSchema.column(name)
+		if !ok {
+			return overlayRow{}, fmt.Errorf("%s: unknown airport column %s", location, name)
+		}
+
+		converted, err := c.convert(field)
+		if err != nil {
+			return overlayRow{}, fmt.Errorf("%s: %w", location, err)
+		}
+		if converted != nil {
+			row.values[name] = converted
+		}
+	}
+
+	if row.ident == "" {
+		return overlayRow{}, fmt.Errorf("%s: missing ident", location)
+	}
+
+	return row, nil
+}
+
+// parseOverlayBool parses the hidden field, accepting yes/no like the ourairports flags
+func parseOverlayBool(field string) (bool, error) {
+	switch strings.ToLower(field) {
+	case "", "no":
+		return false, nil
+	case "yes":
+		return true, nil
+	}
+
+	value, err := strconv.ParseBool(field)
+	if err != nil {
+		return false, fmt.Errorf("column %s: invalid boolean value %q", overlayHiddenField, field)
+	}
+	return value, nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
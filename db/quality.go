@@ -0,0 +1,384 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/viper"
+)
+
+// Data quality checks run on every import
+const (
+	CheckDuplicateICAO      = "duplicate_icao"
+	CheckDuplicateIATA      = "duplicate_iata"
+	CheckInvalidCoordinates = "invalid_coordinates"
+	CheckSwappedCoordinates = "swapped_coordinates"
+	CheckUnknownCountry     = "unknown_country"
+	CheckMalformedICAO      = "malformed_icao"
+	CheckElevationOutlier   = "elevation_outlier"
+)
+
+const (
+	// The lowest airfield (Bar Yehuda, near the Dead Sea) is around -1266 ft
+	// and the highest helipads are below 20000 ft
+	minPlausibleElevationFt = -1500
+	maxPlausibleElevationFt = 20000
+
+	// An airport is reported as having swapped coordinates when it is further than
+	// swappedFarDeg from the center of its region while its swapped position is within swappedNearDeg
+	swappedFarDeg  = 10.0
+	swappedNearDeg = 5.0
+
+	// maxPrintedIssues caps the number of issues printed when a strict import fails
+	maxPrintedIssues = 20
+)
+
+// DataIssue is a single problem found in the imported data
+type DataIssue struct {
+	Check   string `json:"check"`
+	Table   string `json:"table"`
+	Ident   string `json:"ident"`
+	Message string `json:"message"`
+}
+
+// DataQualityError is returned by a strict import when the data quality checks found issues
+type DataQualityError struct {
+	Issues []DataIssue
+}
+
+func (e *DataQualityError) Error() string {
+	return fmt.Sprintf("data quality checks found %d issue(s)", len(e.Issues))
+}
+
+// sqlCheck is a data quality check expressed as a query returning the ident and message of each issue
+type sqlCheck struct {
+	name  string
+	table string
+	query string
+}
+
+var sqlChecks = []sqlCheck{
+	{
+		name:  CheckDuplicateICAO,
+		table: "airports",
+		// Closed airports commonly keep the code of the airport that replaced them
+		query: `SELECT icao_code, 'ICAO code ' || icao_code || ' is used by ' || GROUP_CONCAT(ident, ', ')
+			FROM airports WHERE icao_code IS NOT NULL AND type != 'closed'
+			GROUP BY icao_code HAVING COUNT(*) > 1 ORDER BY icao_code`,
+	},
+	{
+		name:  CheckDuplicateIATA,
+		table: "airports",
+		query: `SELECT iata_code, 'IATA code ' || iata_code || ' is used by ' || GROUP_CONCAT(ident, ', ')
+			FROM airports WHERE iata_code IS NOT NULL AND type != 'closed'
+			GROUP BY iata_code HAVING COUNT(*) > 1 ORDER BY iata_code`,
+	},
+	{
+		name:  CheckInvalidCoordinates,
+		table: "airports",
+		query: `SELECT ident, CASE
+				WHEN latitude_deg IS NULL OR longitude_deg IS NULL THEN 'missing coordinates'
+				WHEN latitude_deg = 0 AND longitude_deg = 0 THEN 'coordinates are exactly 0,0'
+				ELSE 'coordinates ' || latitude_deg || ',' || longitude_deg || ' are out of range'
+			END
+			FROM airports
+			WHERE latitude_deg IS NULL OR longitude_deg IS NULL
+				OR (latitude_deg = 0 AND longitude_deg = 0)
+				OR latitude_deg NOT BETWEEN -90 AND 90
+				OR longitude_deg NOT BETWEEN -180 AND 180
+			ORDER BY ident`,
+	},
+	{
+		name:  CheckUnknownCountry,
+		table: "airports",
+		// Skipped when the source has no country data, e.g. an OpenFlights airports.dat alone
+		query: `SELECT ident, 'country ' || COALESCE(iso_country, '(none)') || ' does not exist'
+			FROM airports
+			WHERE (iso_country IS NULL OR iso_country NOT IN (SELECT code FROM countries))
+				AND EXISTS (SELECT 1 FROM countries)
+			ORDER BY ident`,
+	},
+	{
+		name:  CheckMalformedICAO,
+		table: "airports",
+		query: `SELECT ident, 'ICAO code ' || icao_code || ' is not 4 letters'
+			FROM airports
+			WHERE icao_code IS NOT NULL AND icao_code NOT GLOB '[A-Z][A-Z][A-Z][A-Z]'
+			ORDER BY ident`,
+	},
+	{
+		name:  CheckElevationOutlier,
+		table: "airports",
+		query: fmt.Sprintf(`SELECT ident, 'elevation ' || elevation_ft || ' ft is implausible'
+			FROM airports
+			WHERE elevation_ft NOT BETWEEN %d AND %d
+			ORDER BY ident`, minPlausibleElevationFt, maxPlausibleElevationFt),
+	},
+}
+
+// checkDataQuality runs the data quality checks on the database at dbPath and stores
+// the issues in its import_issues table. The issue count is added to the run warnings.
+// In strict mode (import.strict), any issue fails the import with a DataQualityError.
+func checkDataQuality(dbPath string, run *ImportRun) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var issues []DataIssue
+	for _, check := range sqlChecks {
+		found, err := runSQLCheck(db, check)
+		if err != nil {
+			return fmt.Errorf("failed to run %s check: %w", check.name, err)
+		}
+		issues = append(issues, found...)
+	}
+
+	swapped, err := findSwappedCoordinates(db)
+	if err != nil {
+		return fmt.Errorf("failed to run %s check: %w", CheckSwappedCoordinates, err)
+	}
+	issues = append(issues, swapped...)
+
+	if err := storeIssues(db, issues); err != nil {
+		return err
+	}
+
+	if len(issues) == 0 {
+		fmt.Println("No data quality issue found")
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Check]++
+	}
+
+	fmt.Printf("Found %d data quality issue(s):\n", len(issues))
+	for _, check := range dataQualityChecks() {
+		if counts[check] > 0 {
+			fmt.Printf("  %-20s %6d\n", check, counts[check])
+		}
+	}
+	run.Warnings = append(run.Warnings, fmt.Sprintf("%d data quality issue(s) found", len(issues)))
+
+	if viper.GetBool("import.strict") {
+		for i, issue := range issues {
+			if i == maxPrintedIssues {
+				fmt.Printf("  ... and %d more\n", len(issues)-maxPrintedIssues)
+				break
+			}
+			fmt.Printf("  %s %s: %s\n", issue.Check, issue.Ident, issue.Message)
+		}
+		return &DataQualityError{Issues: issues}
+	}
+
+	return nil
+}
+
+// dataQualityChecks returns the names of every data quality check, in the order they are run
+func dataQualityChecks() []string {
+	checks := make([]string, 0, len(sqlChecks)+1)
+	for _, check := range sqlChecks {
+		checks = append(checks, check.name)
+	}
+	return append(checks, CheckSwappedCoordinates)
+}
+
+func runSQLCheck(db *sql.DB, check sqlCheck) ([]DataIssue, error) {
+	rows, err := db.Query(check.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []DataIssue
+	for rows.Next() {
+		issue := DataIssue{Check: check.name, Table: check.table}
+		if err := rows.Scan(&issue.Ident, &issue.Message); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, rows.Err()
+}
+
+// findSwappedCoordinates reports the airports lying far from the center of their region,
+// while their position with latitude and longitude swapped would lie close to it.
+// The center is the median position, so that the outliers themselves do not move it.
+func findSwappedCoordinates(db *sql.DB) ([]DataIssue, error) {
+	type position struct {
+		ident, region string
+		lat, lon      float64
+	}
+
+	rows, err := db.Query(`SELECT ident, iso_region, latitude_deg, longitude_deg FROM airports
+		WHERE iso_region IS NOT NULL AND latitude_deg IS NOT NULL AND longitude_deg IS NOT NULL
+		ORDER BY ident`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []position
+	lats := map[string][]float64{}
+	lons := map[string][]float64{}
+	for rows.Next() {
+		var p position
+		if err := rows.Scan(&p.ident, &p.region, &p.lat, &p.lon); err != nil {
+			return nil, err
+		}
+		positions = append(positions, p)
+		lats[p.region] = append(lats[p.region], p.lat)
+		lons[p.region] = append(lons[p.region], p.lon)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The center of a region is meaningless with too few airports
+	centers := map[string][2]float64{}
+	for region := range lats {
+		if len(lats[region]) >= 3 {
+			centers[region] = [2]float64{median(lats[region]), median(lons[region])}
+		}
+	}
+
+	var issues []DataIssue
+	for _, p := range positions {
+		center, ok := centers[p.region]
+		if !ok {
+			continue
+		}
+		centerLat, centerLon := center[0], center[1]
+
+		if degreesApart(p.lat, p.lon, centerLat, centerLon) > swappedFarDeg &&
+			degreesApart(p.lon, p.lat, centerLat, centerLon) < swappedNearDeg {
+			issues = append(issues, DataIssue{
+				Check:   CheckSwappedCoordinates,
+				Table:   "airports",
+				Ident:   p.ident,
+				Message: fmt.Sprintf("coordinates %g,%g look swapped for region %s", p.lat, p.lon, p.region),
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// median returns the median of values, sorting them in place
+func median(values []float64) float64 {
+	slices.Sort(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
+
+func degreesApart(lat1, lon1, lat2, lon2 float64) float64 {
+	return math.Hypot(lat1-lat2, lon1-lon2)
+}
+
+func storeIssues(db *sql.DB, issues []DataIssue) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO import_issues (check_name, table_name, ident, message) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, issue := range issues {
+		_, err := stmt.Exec(issue.Check, issue.Table, issue.Ident, issue.Message)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert data issue: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ImportIssues returns the data quality issues found by the last import of the database.
+// When check is set, only the issues of that check are returned.
+func ImportIssues(db *sql.DB, check string, limit int) ([]DataIssue, int, error) {
+	where := ""
+	var args []interface{}
+	if check != "" {
+		where = " WHERE check_name = ?"
+		args = append(args, check)
+	}
+
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM import_issues"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count data issues: %w", err)
+	}
+
+	rows, err := db.Query("SELECT check_name, table_name, ident, message FROM import_issues"+where+" ORDER BY id LIMIT ?",
+		append(args, limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read data issues: %w", err)
+	}
+	defer rows.Close()
+
+	issues := []DataIssue{}
+	for rows.Next() {
+		var issue DataIssue
+		if err := rows.Scan(&issue.Check, &issue.Table, &issue.Ident, &issue.Message); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan data issue: %w", err)
+		}
+		issues = append(issues, issue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read data issues: %w", err)
+	}
+
+	return issues, total, nil
+}
+
+// LiveImportIssues returns the data quality issues of the live database, see ImportIssues.
+// It returns no issue if the database was never initialized.
+func LiveImportIssues(check string, limit int) ([]DataIssue, int, error) {
+	livePath := DatabasePath()
+	if _, err := os.Stat(livePath); errors.Is(err, os.ErrNotExist) {
+		return []DataIssue{}, 0, nil
+	}
+
+	db, err := sql.Open("sqlite3", livePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if !hasTable(db, "main", "import_issues") {
+		return []DataIssue{}, 0, nil
+	}
+
+	return ImportIssues(db, check, limit)
+}
+
+// IsDataQualityCheck tells whether name is a known data quality check
+func IsDataQualityCheck(name string) bool {
+	return slices.Contains(dataQualityChecks(), name)
+}
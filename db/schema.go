@@ -0,0 +1,301 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// columnType is the type an imported CSV field is converted to.
+// It must match the column type declared by the migrations.
+type columnType string
+
+const (
+	textColumn    columnType = "TEXT"
+	integerColumn columnType = "INTEGER"
+	realColumn    columnType = "REAL"
+)
+
+// column describes a single CSV column and the typed table column it is stored in
+type column struct {
+	name string
+	kind columnType
+}
+
+// tableSchema declares the expected layout of an ourairports CSV file
+// and the table it is imported into. The CSV header must match the
+// declared columns exactly, in the same order.
+type tableSchema struct {
+	table   string
+	csvFile string
+	columns []column
+}
+
+var airportsSchema = tableSchema{
+	table:   "airports",
+	csvFile: "airports.csv",
+	columns: []column{
+		{"id", integerColumn},
+		{"ident", textColumn},
+		{"type", textColumn},
+		{"name", textColumn},
+		{"latitude_deg", realColumn},
+		{"longitude_deg", realColumn},
+		{"elevation_ft", integerColumn},
+		{"continent", textColumn},
+		{"iso_country", textColumn},
+		{"iso_region", textColumn},
+		{"municipality", textColumn},
+		{"scheduled_service", textColumn},
+		{"icao_code", textColumn},
+		{"iata_code", textColumn},
+		{"gps_code", textColumn},
+		{"local_code", textColumn},
+		{"home_link", textColumn},
+		{"wikipedia_link", textColumn},
+		{"keywords", textColumn},
+	},
+}
+
+var countriesSchema = tableSchema{
+	table:   "countries",
+	csvFile: "countries.csv",
+	columns: []column{
+		{"id", integerColumn},
+		{"code", textColumn},
+		{"name", textColumn},
+		{"continent", textColumn},
+		{"wikipedia_link", textColumn},
+		{"keywords", textColumn},
+	},
+}
+
+var regionsSchema = tableSchema{
+	table:   "regions",
+	csvFile: "regions.csv",
+	columns: []column{
+		{"id", integerColumn},
+		{"code", textColumn},
+		{"local_code", textColumn},
+		{"name", textColumn},
+		{"continent", textColumn},
+		{"iso_country", textColumn},
+		{"wikipedia_link", textColumn},
+		{"keywords", textColumn},
+	},
+}
+
+var runwaysSchema = tableSchema{
+	table:   "runways",
+	csvFile: "runways.csv",
+	columns: []column{
+		{"id", integerColumn},
+		{"airport_ref", integerColumn},
+		{"airport_ident", textColumn},
+		{"length_ft", integerColumn},
+		{"width_ft", integerColumn},
+		{"surface", textColumn},
+		{"lighted", integerColumn},
+		{"closed", integerColumn},
+		{"le_ident", textColumn},
+		{"le_latitude_deg", realColumn},
+		{"le_longitude_deg", realColumn},
+		{"le_elevation_ft", integerColumn},
+		{"le_heading_degT", realColumn},
+		{"le_displaced_threshold_ft", integerColumn},
+		{"he_ident", textColumn},
+		{"he_latitude_deg", realColumn},
+		{"he_longitude_deg", realColumn},
+		{"he_elevation_ft", integerColumn},
+		{"he_heading_degT", realColumn},
+		{"he_displaced_threshold_ft", integerColumn},
+	},
+}
+
+var navaidsSchema = tableSchema{
+	table:   "navaids",
+	csvFile: "navaids.csv",
+	columns: []column{
+		{"id", integerColumn},
+		{"filename", textColumn},
+		{"ident", textColumn},
+		{"name", textColumn},
+		{"type", textColumn},
+		{"frequency_khz", integerColumn},
+		{"latitude_deg", realColumn},
+		{"longitude_deg", realColumn},
+		{"elevation_ft", integerColumn},
+		{"iso_country", textColumn},
+		{"dme_frequency_khz", integerColumn},
+		{"dme_channel", textColumn},
+		{"dme_latitude_deg", realColumn},
+		{"dme_longitude_deg", realColumn},
+		{"dme_elevation_ft", integerColumn},
+		{"slaved_variation_deg", realColumn},
+		{"magnetic_variation_deg", realColumn},
+		{"usageType", textColumn},
+		{"power", textColumn},
+		{"associated_airport", textColumn},
+	},
+}
+
+var frequenciesSchema = tableSchema{
+	table:   "frequencies",
+	csvFile: "airport-frequencies.csv",
+	columns: []column{
+		{"id", integerColumn},
+		{"airport_ref", integerColumn},
+		{"airport_ident", textColumn},
+		{"type", textColumn},
+		{"description", textColumn},
+		{"frequency_mhz", realColumn},
+	},
+}
+
+// importedSchemas lists every imported table, in import order
+var importedSchemas = []tableSchema{
+	airportsSchema,
+	countriesSchema,
+	regionsSchema,
+	runwaysSchema,
+	navaidsSchema,
+	frequenciesSchema,
+}
+
+// SchemaDriftError is returned when a CSV header no longer matches the declared schema,
+// typically because upstream added, removed or reordered columns.
+type SchemaDriftError struct {
+	File    string
+	Missing []string
+	Added   []string
+	// Reordered is set when the same columns are present but not in the expected order
+	Reordered bool
+	Expected  []string
+	Actual    []string
+}
+
+func (e *SchemaDriftError) Error() string {
+	var details []string
+	if len(e.Missing) > 0 {
+		details = append(details, fmt.Sprintf("missing columns %v", e.Missing))
+	}
+	if len(e.Added) > 0 {
+		details = append(details, fmt.Sprintf("unexpected columns %v", e.Added))
+	}
+	if e.Reordered {
+		details = append(details, fmt.Sprintf("columns reordered: expected %v, got %v", e.Expected, e.Actual))
+	}
+	return fmt.Sprintf("schema drift in %s: %s", e.File, strings.Join(details, "; "))
+}
+
+// columnNames returns the declared column names, in order
+func (t tableSchema) columnNames() []string {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// insertSQL returns the INSERT statement for the schema, naming every column
+func (t tableSchema) insertSQL() string {
+	placeholders := strings.Repeat("?,", len(t.columns)-1) + "?"
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.table, strings.Join(t.columnNames(), ", "), placeholders)
+}
+
+// checkHeader verifies that the CSV header matches the declared columns exactly
+func (t tableSchema) checkHeader(header []string) error {
+	expected := t.columnNames()
+
+	declared := make(map[string]bool, len(expected))
+	for _, name := range expected {
+		declared[name] = true
+	}
+	present := make(map[string]bool, len(header))
+	for _, name := range header {
+		present[name] = true
+	}
+
+	drift := &SchemaDriftError{File: t.csvFile, Expected: expected, Actual: header}
+	for _, name := range expected {
+		if !present[name] {
+			drift.Missing = append(drift.Missing, name)
+		}
+	}
+	for _, name := range header {
+		if !declared[name] {
+			drift.Added = append(drift.Added, name)
+		}
+	}
+
+	if len(drift.Missing) == 0 && len(drift.Added) == 0 {
+		if slices.Equal(header, expected) {
+			return nil
+		}
+		drift.Reordered = true
+	}
+
+	return drift
+}
+
+// convertRecord converts a CSV record into typed SQL arguments.
+// Empty fields become NULL.
+func (t tableSchema) convertRecord(record []string) ([]interface{}, error) {
+	args := make([]interface{}, len(t.columns))
+	for i, c := range t.columns {
+		v, err := c.convert(record[i])
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// column returns the declared column with the given name
+func (t tableSchema) column(name string) (column, bool) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+// convert converts a CSV field into a typed SQL argument. An empty field becomes NULL.
+func (c column) convert(field string) (interface{}, error) {
+	value := strings.TrimSpace(field)
+	if value == "" {
+		return nil, nil
+	}
+
+	switch c.kind {
+	case integerColumn:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: invalid %s value %q", c.name, c.kind, field)
+		}
+		return v, nil
+	case realColumn:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: invalid %s value %q", c.name, c.kind, field)
+		}
+		return v, nil
+	default:
+		return field, nil
+	}
+}
+
+// ImportedTables returns the names of the imported tables, in import order
+func ImportedTables() []string {
+	tables := make([]string, 0, len(importedSchemas))
+	for _, schema := range importedSchemas {
+		tables = append(tables, schema.table)
+	}
+	return tables
+}
@@ -0,0 +1,211 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const countriesHeader = `"id","code","name","continent","wikipedia_link","keywords"`
+
+func TestCheckHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		err    string
+	}{
+		{
+			name:   "unchanged",
+			header: []string{"id", "code", "name", "continent", "wikipedia_link", "keywords"},
+		},
+		{
+			name:   "reordered",
+			header: []string{"id", "name", "code", "continent", "wikipedia_link", "keywords"},
+			err: "schema drift in countries.csv: columns reordered: expected [id code name continent wikipedia_link keywords], " +
+				"got [id name code continent wikipedia_link keywords]",
+		},
+		{
+			name:   "missing",
+			header: []string{"id", "code", "name", "wikipedia_link"},
+			err:    "schema drift in countries.csv: missing columns [continent keywords]",
+		},
+		{
+			name:   "added",
+			header: []string{"id", "code", "name", "continent", "wikipedia_link", "keywords", "population"},
+			err:    "schema drift in countries.csv: unexpected columns [population]",
+		},
+		{
+			name:   "renamed",
+			header: []string{"id", "iso_code", "name", "continent", "wikipedia_link", "keywords"},
+			err:    "schema drift in countries.csv: missing columns [code]; unexpected columns [iso_code]",
+		},
+	}
+
+	for _, tt := range tests {
+		err := countriesSchema.checkHeader(tt.header)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: checkHeader() error = %v, want none", tt.name, err)
+			}
+			continue
+		}
+
+		var drift *SchemaDriftError
+		if !errors.As(err, &drift) {
+			t.Fatalf("%s: checkHeader() error = %v, want a SchemaDriftError", tt.name, err)
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%s: checkHeader() error =\n%s\nwant\n%s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestColumnConvert(t *testing.T) {
+	tests := []struct {
+		column column
+		field  string
+		want   interface{}
+		err    string
+	}{
+		{column: column{"elevation_ft", integerColumn}, field: "392", want: int64(392)},
+		{column: column{"elevation_ft", integerColumn}, field: " -12 ", want: int64(-12)},
+		{column: column{"elevation_ft", integerColumn}, field: "", want: nil},
+		{column: column{"elevation_ft", integerColumn}, field: "  ", want: nil},
+		{column: column{"elevation_ft", integerColumn}, field: "12.5", err: `column elevation_ft: invalid INTEGER value "12.5"`},
+		{column: column{"latitude_deg", realColumn}, field: "49.0097", want: 49.0097},
+		{column: column{"latitude_deg", realColumn}, field: "", want: nil},
+		{column: column{"latitude_deg", realColumn}, field: "north", err: `column latitude_deg: invalid REAL value "north"`},
+		{column: column{"name", textColumn}, field: "Orly", want: "Orly"},
+		{column: column{"name", textColumn}, field: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.column.convert(tt.field)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("convert(%q) error = %v, want %s", tt.field, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("convert(%q) error = %v", tt.field, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convert(%q) = %#v, want %#v", tt.field, got, tt.want)
+		}
+	}
+}
+
+// newTestImport returns a migrated database and a data directory holding countries.csv with the given content
+func newTestImport(t *testing.T, content string) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "ask.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := filepath.Join(dir, "data")
+	if err := os.Mkdir(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, countriesSchema.csvFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dbPath, dataDir
+}
+
+func TestImportCSV(t *testing.T) {
+	dbPath, dataDir := newTestImport(t, countriesHeader+"\n"+
+		`302672,"FR","France","EU","https://en.wikipedia.org/wiki/France",""`+"\n"+
+		`302673,"XX","Nowhere","",,`+"\n")
+
+	count, err := importCSV(dbPath, dataDir, countriesSchema, sourceInfo{checksum: "sha256:test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("importCSV() = %d records, want 2", count)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var (
+		idType    string
+		continent sql.NullString
+		keywords  sql.NullString
+	)
+	err = db.QueryRow(`SELECT typeof(id), continent, keywords FROM countries WHERE code = 'XX'`).Scan(&idType, &continent, &keywords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idType != "integer" {
+		t.Errorf("id stored as %s, want integer", idType)
+	}
+	if continent.Valid || keywords.Valid {
+		t.Errorf("empty fields stored as %v and %v, want NULL", continent, keywords)
+	}
+}
+
+func TestImportCSVSchemaDrift(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		err    string
+	}{
+		{
+			name:   "reordered",
+			header: `"id","name","code","continent","wikipedia_link","keywords"`,
+			err:    "schema drift in countries.csv: columns reordered",
+		},
+		{
+			name:   "missing",
+			header: `"id","code","name","continent","wikipedia_link"`,
+			err:    "schema drift in countries.csv: missing columns [keywords]",
+		},
+		{
+			name:   "added",
+			header: countriesHeader + `,"population"`,
+			err:    "schema drift in countries.csv: unexpected columns [population]",
+		},
+	}
+
+	for _, tt := range tests {
+		dbPath, dataDir := newTestImport(t, tt.header+"\n")
+
+		_, err := importCSV(dbPath, dataDir, countriesSchema, sourceInfo{})
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%s: importCSV() error = %v, want %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestImportCSVInvalidValue(t *testing.T) {
+	dbPath, dataDir := newTestImport(t, countriesHeader+"\n"+
+		`302672,"FR","France","EU","",""`+"\n"+
+		`abc,"XX","Nowhere","","",""`+"\n")
+
+	_, err := importCSV(dbPath, dataDir, countriesSchema, sourceInfo{})
+	want := `countries.csv line 3: column id: invalid INTEGER value "abc"`
+	if err == nil || err.Error() != want {
+		t.Errorf("importCSV() error = %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SearchIndexTable is the FTS5 table indexing the searchable airport columns
+const SearchIndexTable = "airports_fts"
+
+// buildSearchIndex (re)builds the full-text index of the airports of the database at dbPath.
+// FTS5 is only available when built with the sqlite_fts5 tag: without it the index is
+// skipped and the airport search falls back to plain LIKE matching.
+func buildSearchIndex(dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS ` + SearchIndexTable)
+	if err != nil {
+		return fmt.Errorf("failed to drop search index: %w", err)
+	}
+
+	// External content table: the index only stores the tokens, the rows stay in airports
+	_, err = db.Exec(`CREATE VIRTUAL TABLE ` + SearchIndexTable + ` USING fts5(
+		name, municipality, keywords, ident, icao_code, iata_code,
+		content='airports', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	)`)
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		fmt.Println("Warning: SQLite was built without FTS5 (sqlite_fts5 build tag), skipping the airport search index")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO ` + SearchIndexTable + `(` + SearchIndexTable + `) VALUES ('rebuild')`)
+	if err != nil {
+		return fmt.Errorf("failed to build search index: %w", err)
+	}
+
+	fmt.Println("Successfully built the airport search index")
+	return nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/viper"
+)
+
+// snapshotIDLayout is the time layout starting each snapshot id, so that ids sort by creation
+const snapshotIDLayout = "20060102-150405"
+
+// liveSnapshotFile is the file of the snapshot directory holding the id of the snapshot installed
+// as the live database
+const liveSnapshotFile = "live"
+
+// Snapshot is a database built by a past import, kept in the snapshots directory
+type Snapshot struct {
+	ID              string
+	Path            string
+	CreatedAt       time.Time
+	GitCommitHash   string
+	ContentChecksum string
+	AirportCount    int
+	// Live is set for the snapshot currently installed as the live database
+	Live bool
+}
+
+// SnapshotDir returns the directory holding the database snapshots
+func SnapshotDir() string {
+	return filepath.Join(filepath.Dir(DatabasePath()), "snapshots")
+}
+
+// SnapshotPath returns the path of the snapshot with the given id, which must exist
+func SnapshotPath(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid snapshot id %q", id)
+	}
+
+	path := filepath.Join(SnapshotDir(), id+".db")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("snapshot %s not found", id)
+	}
+	return path, nil
+}
+
+// takeSnapshot keeps a copy of the new database at dbPath in the snapshots directory, tagged with its source.
+// The snapshot is a copy rather than a hard link, as the live database is still written to after its
+// installation, e.g. by the migrations of a newer binary or the history of a failed import.
+func takeSnapshot(dbPath string, source sourceInfo) (string, error) {
+	if err := os.MkdirAll(SnapshotDir(), os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	tag := strings.TrimPrefix(source.checksum, "sha256:")
+	if source.commitHash != "" {
+		tag = source.commitHash
+	}
+	if len(tag) > 7 {
+		tag = tag[:7]
+	}
+
+	// Ids only have a one-second resolution: number the snapshots taken within the same second
+	base := time.Now().UTC().Format(snapshotIDLayout) + "-" + tag
+	id := base
+	for n := 2; ; n++ {
+		path := filepath.Join(SnapshotDir(), id+".db")
+		err := copyNewFile(dbPath, path)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			os.Remove(path)
+			return "", fmt.Errorf("failed to take snapshot: %w", err)
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// ListSnapshots returns the database snapshots, newest first
+func ListSnapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(SnapshotDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	liveID := liveSnapshot()
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".db")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		snapshot := Snapshot{ID: id, Path: filepath.Join(SnapshotDir(), entry.Name())}
+		if len(id) >= len(snapshotIDLayout) {
+			snapshot.CreatedAt, _ = time.Parse(snapshotIDLayout, id[:len(snapshotIDLayout)])
+		}
+
+		snapshot.Live = id == liveID
+
+		if err := readSnapshotSource(&snapshot); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", id, err)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+
+	return snapshots, nil
+}
+
+// readSnapshotSource fills the source and airport count of the snapshot from its import status
+func readSnapshotSource(snapshot *Snapshot) error {
+	db, err := sql.Open("sqlite3", "file:"+snapshot.Path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.QueryRow(`SELECT COALESCE(git_commit_hash, ''), COALESCE(content_checksum, ''), record_count
+		FROM import_status WHERE table_name = 'airports'`).Scan(&snapshot.GitCommitHash, &snapshot.ContentChecksum, &snapshot.AirportCount)
+}
+
+// RollbackSnapshot atomically installs the snapshot with the given id as the live database.
+// The import history of the live database is kept, and the rollback is appended to it.
+// A running server picks it up like any other database replacement.
+func RollbackSnapshot(id string) error {
+	path, err := SnapshotPath(id)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := DatabasePath() + ".tmp"
+	os.Remove(tmpPath)
+
+	if err := copyFile(path, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to prepare snapshot %s: %w", id, err)
+	}
+
+	if err := recordRollback(tmpPath, id); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to record the rollback in the import history: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, DatabasePath()); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	return recordLiveSnapshot(id)
+}
+
+// CopySnapshot copies the snapshot with the given id to a temporary file and returns its path,
+// so that it can be served, and migrated, without altering the snapshot. The caller removes the copy.
+func CopySnapshot(id string) (string, error) {
+	path, err := SnapshotPath(id)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp("", "ask-snapshot-"+id+"-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot copy: %w", err)
+	}
+	tmp.Close()
+
+	if err := copyFile(path, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to copy snapshot %s: %w", id, err)
+	}
+
+	return tmp.Name(), nil
+}
+
+// liveSnapshot returns the id of the snapshot installed as the live database, empty when unknown
+func liveSnapshot() string {
+	content, err := os.ReadFile(filepath.Join(SnapshotDir(), liveSnapshotFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// recordLiveSnapshot records the id of the snapshot installed as the live database
+func recordLiveSnapshot(id string) error {
+	path := filepath.Join(SnapshotDir(), liveSnapshotFile)
+	if err := os.WriteFile(path+".tmp", []byte(id+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record the live snapshot: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to record the live snapshot: %w", err)
+	}
+	return nil
+}
+
+// PruneSnapshots removes the oldest snapshots, keeping the newest keep ones and the live one.
+// It returns the ids of the removed snapshots.
+func PruneSnapshots(keep int) ([]string, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	kept := 0
+	for _, snapshot := range snapshots {
+		if kept < keep || snapshot.Live {
+			kept++
+			continue
+		}
+
+		if err := os.Remove(snapshot.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove snapshot %s: %w", snapshot.ID, err)
+		}
+		removed = append(removed, snapshot.ID)
+	}
+
+	return removed, nil
+}
+
+// SnapshotsToKeep returns the number of snapshots kept after each import (snapshots.keep)
+func SnapshotsToKeep() int {
+	return viper.GetInt("snapshots.keep")
+}
+
+// copyFile copies src to dst, replacing it, and flushes the copy to disk
+func copyFile(src string, dst string) error {
+	return copyFileWith(src, dst, os.O_TRUNC)
+}
+
+// copyNewFile copies src to dst and flushes the copy to disk. It fails with an os.ErrExist error,
+// leaving dst untouched, when dst already exists.
+func copyNewFile(src string, dst string) error {
+	return copyFileWith(src, dst, os.O_EXCL)
+}
+
+// copyFileWith copies src to dst, created with the given extra open flag, and flushes the copy to disk
+func copyFileWith(src string, dst string, flag int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
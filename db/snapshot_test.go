@@ -0,0 +1,149 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// useTestRepository points the database and its snapshots to a temporary repository
+func useTestRepository(t *testing.T) {
+	t.Helper()
+
+	repoDir := t.TempDir()
+	viper.Set("repository", repoDir)
+	viper.Set("db", "db")
+	t.Cleanup(viper.Reset)
+
+	if err := os.MkdirAll(filepath.Dir(DatabasePath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTakeSnapshotSameSecond(t *testing.T) {
+	useTestRepository(t)
+
+	dbPath := filepath.Join(t.TempDir(), "ask.db")
+	source := sourceInfo{commitHash: "0123456789abcdef"}
+
+	var ids []string
+	for _, content := range []string{"first", "second", "third"} {
+		if err := os.WriteFile(dbPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := takeSnapshot(dbPath, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Snapshots taken within the same second get distinct ids, each keeping its own content
+	seen := map[string]bool{}
+	for i, id := range ids {
+		if seen[id] {
+			t.Fatalf("takeSnapshot() returned %s twice: %v", id, ids)
+		}
+		seen[id] = true
+
+		path, err := SnapshotPath(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"first", "second", "third"}[i]; string(content) != want {
+			t.Errorf("snapshot %s = %q, want %q", id, content, want)
+		}
+	}
+}
+
+// writeTestDatabase builds a database at path holding the given import runs
+func writeTestDatabase(t *testing.T, path string, runs ...string) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO import_status (table_name, last_import_date, git_commit_hash, record_count)
+		VALUES ('airports', '', '0123456789abcdef', 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, startedAt := range runs {
+		run := newImportRun()
+		run.StartedAt = startedAt
+		run.finish(ImportSucceeded)
+		if err := insertRun(db, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRollbackSnapshotKeepsHistory(t *testing.T) {
+	useTestRepository(t)
+
+	// The snapshot was taken after the first run, the live database after the second one
+	snapshotPath := filepath.Join(t.TempDir(), "ask.db")
+	writeTestDatabase(t, snapshotPath, "first")
+	id, err := takeSnapshot(snapshotPath, sourceInfo{commitHash: "0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestDatabase(t, DatabasePath(), "first", "second")
+
+	if err := RollbackSnapshot(id); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := LiveImportHistory("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("LiveImportHistory() = %d runs, want 3", len(runs))
+	}
+	for i, want := range []struct {
+		id        int64
+		startedAt string
+		outcome   string
+	}{{3, "", ImportRolledBack}, {2, "second", ImportSucceeded}, {1, "first", ImportSucceeded}} {
+		run := runs[i]
+		if run.ID != want.id || (want.startedAt != "" && run.StartedAt != want.startedAt) || run.Outcome != want.outcome {
+			t.Errorf("run %d = #%d %s %s, want #%d %s %s", i, run.ID, run.StartedAt, run.Outcome, want.id, want.startedAt, want.outcome)
+		}
+	}
+	if runs[0].GitCommitHash != "0123456789abcdef" {
+		t.Errorf("rollback run source = %q, want the snapshot source", runs[0].GitCommitHash)
+	}
+
+	// The next run does not reuse the id of a carried over one
+	if err := appendToLiveHistory(newImportRun()); err != nil {
+		t.Fatal(err)
+	}
+	runs, err = LiveImportHistory("", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs[0].ID != 4 {
+		t.Errorf("next run id = %d, want 4", runs[0].ID)
+	}
+
+	if live := liveSnapshot(); live != id {
+		t.Errorf("live snapshot = %q, want %q", live, id)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"fmt"
+)
+
+// Values of the airports source column
+const (
+	SourceOurAirports = "ourairports"
+	SourceOpenFlights = "openflights"
+	SourceOverlay     = "overlay"
+)
+
+// DataSource is a dataset the database can be built from. InitializeDatabase identifies
+// the data, then has the source import it into the airports table and any other table
+// it can fill. Implementations live in this package.
+type DataSource interface {
+	// Name identifies the dataset, it is stored in the source column of the imported airports
+	Name() string
+
+	// identify returns the version of the data: a git commit or a content checksum
+	identify() (sourceInfo, error)
+
+	// importInto imports the data into the freshly migrated database at dbPath.
+	// It returns the number of records imported per table.
+	importInto(dbPath string, source sourceInfo) (map[string]int, error)
+}
+
+// SourceChecksum returns the content checksum of the data of the source, as recorded in import_status
+func SourceChecksum(source DataSource) (string, error) {
+	info, err := source.identify()
+	if err != nil {
+		return "", err
+	}
+	return info.checksum, nil
+}
+
+// ourAirportsSource is the ourairports.com dataset: the CSV files of a directory,
+// usually a checkout of the ourairports-data git repository
+type ourAirportsSource struct {
+	dataDir string
+}
+
+// NewOurAirportsSource returns the ourairports dataset held by the CSV files of dataDir
+func NewOurAirportsSource(dataDir string) DataSource {
+	return &ourAirportsSource{dataDir: dataDir}
+}
+
+func (s *ourAirportsSource) Name() string {
+	return SourceOurAirports
+}
+
+func (s *ourAirportsSource) identify() (sourceInfo, error) {
+	return getSourceInfo(s.dataDir)
+}
+
+func (s *ourAirportsSource) importInto(dbPath string, source sourceInfo) (map[string]int, error) {
+	counts := make(map[string]int, len(importedSchemas))
+	for _, schema := range importedSchemas {
+		recordCount, err := importCSV(dbPath, s.dataDir, schema, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s data: %w", schema.table, err)
+		}
+		counts[schema.table] = recordCount
+
+		fmt.Printf("Successfully imported %d %s records\n", recordCount, schema.table)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SpatialIndexTable is the R*Tree table indexing the airport positions.
+// Each airport is stored as a point: min and max of a dimension are equal.
+const SpatialIndexTable = "airports_rtree"
+
+// buildSpatialIndex (re)builds the R*Tree index of the airport positions of the database at dbPath
+func buildSpatialIndex(dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS ` + SpatialIndexTable)
+	if err != nil {
+		return fmt.Errorf("failed to drop spatial index: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE VIRTUAL TABLE ` + SpatialIndexTable + ` USING rtree(id, min_lat, max_lat, min_lon, max_lon)`)
+	if err != nil {
+		return fmt.Errorf("failed to create spatial index: %w", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO ` + SpatialIndexTable + ` (id, min_lat, max_lat, min_lon, max_lon)
+		SELECT id, latitude_deg, latitude_deg, longitude_deg, longitude_deg FROM airports
+		WHERE id IS NOT NULL AND latitude_deg IS NOT NULL AND longitude_deg IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to build spatial index: %w", err)
+	}
+
+	fmt.Println("Successfully built the airport spatial index")
+	return nil
+}
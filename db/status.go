@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ImportStatus is the last import of a table, as recorded in the import_status table
+type ImportStatus struct {
+	TableName       string
+	LastImportDate  string
+	GitCommitHash   string
+	GitCommitDate   string
+	RecordCount     int
+	ContentChecksum string
+	SourceURL       string
+}
+
+// LiveImportStatus returns the import status of every table of the live database.
+// It returns an empty list if the database was never initialized.
+func LiveImportStatus() ([]ImportStatus, error) {
+	dbPath := DatabasePath()
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT table_name, last_import_date, COALESCE(git_commit_hash, ''), COALESCE(git_commit_date, ''),
+		record_count, COALESCE(content_checksum, ''), COALESCE(source_url, '')
+		FROM import_status ORDER BY table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import status: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []ImportStatus
+	for rows.Next() {
+		var status ImportStatus
+		err := rows.Scan(&status.TableName, &status.LastImportDate, &status.GitCommitHash, &status.GitCommitDate, &status.RecordCount, &status.ContentChecksum, &status.SourceURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import status: %w", err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read import status: %w", err)
+	}
+
+	return statuses, nil
+}
+
+// LiveDataSource returns the name of the dataset the live database was built from, e.g. SourceOpenFlights.
+// It returns an empty string if the database was never initialized.
+func LiveDataSource() (string, error) {
+	dbPath := DatabasePath()
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var source string
+	err = db.QueryRow("SELECT COALESCE(source, '') FROM airports WHERE source IS NOT ? LIMIT 1", SourceOverlay).Scan(&source)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the data source: %w", err)
+	}
+
+	return source, nil
+}
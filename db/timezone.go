@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"ask/timezone"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// assignTimezones resolves the IANA timezone of every airport of the database at dbPath
+// that has coordinates but no timezone yet, and stores it in the timezone column
+func assignTimezones(dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	type position struct {
+		rowid    int64
+		lat, lon float64
+	}
+
+	rows, err := db.Query(`SELECT rowid, latitude_deg, longitude_deg FROM airports
+		WHERE timezone IS NULL AND latitude_deg IS NOT NULL AND longitude_deg IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to read airport positions: %w", err)
+	}
+
+	var positions []position
+	for rows.Next() {
+		var p position
+		if err := rows.Scan(&p.rowid, &p.lat, &p.lon); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan airport position: %w", err)
+		}
+		positions = append(positions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read airport positions: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.Prepare("UPDATE airports SET timezone = ? WHERE rowid = ?")
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare update statement: %w", err)
+	}
+	defer stmt.Close()
+
+	resolved := 0
+	for _, p := range positions {
+		name, err := timezone.Lookup(p.lat, p.lon)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if name == "" {
+			continue
+		}
+
+		if _, err := stmt.Exec(name, p.rowid); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to store airport timezone: %w", err)
+		}
+		resolved++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	fmt.Printf("Successfully resolved the timezone of %d airports\n", resolved)
+	return nil
+}
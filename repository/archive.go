@@ -0,0 +1,129 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package repository
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractArchive extracts the CSV files of a .tar.gz, .tgz or .zip archive into a new
+// temporary directory and returns it. Files are flattened, so the archive may hold
+// them at its root or in a single sub-directory. The caller must remove the directory.
+func ExtractArchive(archivePath string) (string, error) {
+	dir, err := os.MkdirTemp("", "ask-data-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		err = extractTarGz(archivePath, dir)
+	case strings.HasSuffix(lower, ".zip"):
+		err = extractZip(archivePath, dir)
+	default:
+		err = fmt.Errorf("unsupported archive format %s - expected .tar.gz, .tgz or .zip", archivePath)
+	}
+
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func extractTarGz(archivePath string, dir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := extractCSV(header.Name, tr, dir); err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(archivePath string, dir string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from zip archive: %w", f.Name, err)
+		}
+
+		err = extractCSV(f.Name, rc, dir)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractCSV writes an archive entry into dir under its base name if it is a CSV file.
+// Only the base name is used, so entries can never be written outside of dir.
+func extractCSV(name string, r io.Reader, dir string) error {
+	base := path.Base(name)
+	if !strings.HasSuffix(strings.ToLower(base), ".csv") {
+		return nil
+	}
+
+	target := filepath.Join(dir, base)
+	if IsDirectoryExists(target) {
+		return fmt.Errorf("archive holds more than one %s", base)
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -16,7 +16,3 @@ func EnsureDbDirExists() {
 
 	EnsureDirectoryExists(dbDir)
 }
-
-// Ensure the db exists
-
-// Ensure the db has the latest data
@@ -7,9 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/spf13/cobra"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/spf13/viper"
 )
 
@@ -40,6 +44,12 @@ func EnsureDirectoryExists(path string) {
 	}
 }
 
+// DataDir returns the directory holding the ourairports data checkout
+func DataDir() string {
+	repoDir := viper.GetString("repository")
+	return repoDir + "/" + viper.GetString("data")
+}
+
 func EnsureRepositoryDirExists() {
 	repoDir := viper.GetString("repository")
 
@@ -48,31 +58,291 @@ func EnsureRepositoryDirExists() {
 
 // Ensure the repository has a copy of ourairport git repo cloned
 func EnsureDataDirExists() {
-	repoDir := viper.GetString("repository")
-	dataDir := repoDir + "/" + viper.GetString("data")
+	EnsureDirectoryExists(DataDir())
+}
 
-	EnsureDirectoryExists(dataDir)
+// Source describes where the airport data is pulled from
+type Source struct {
+	// URL of the git remote
+	URL string
+	// Branch to follow, the remote default branch when empty
+	Branch string
+	// Ref pins a commit or tag to check out instead of following a branch
+	Ref string
 }
 
-func RetrieveDataFromGit() {
-	repoDir := viper.GetString("repository")
-	dataDir := repoDir + "/" + viper.GetString("data")
+// ConfiguredSource returns the data source set in the configuration
+func ConfiguredSource() Source {
+	return Source{
+		URL:    viper.GetString("source.url"),
+		Branch: viper.GetString("source.branch"),
+		Ref:    viper.GetString("source.ref"),
+	}
+}
+
+// RetrieveDataFromGit clones the configured data repository into the data directory,
+// or fetches the latest changes if it was already cloned, and then checks out
+// the pinned ref or the head of the followed branch.
+func RetrieveDataFromGit() error {
+	source := ConfiguredSource()
+	dataDir := DataDir()
 	gitDir := dataDir + "/.git"
 
+	var r *git.Repository
+	var err error
 	if IsDirectoryExists(gitDir) {
 		// do an update instead
-		r, err := git.PlainOpen(dataDir)
-		cobra.CheckErr(err)
-		w, err := r.Worktree()
-		cobra.CheckErr(err)
-		err = w.Pull(&git.PullOptions{RemoteName: "origin"})
-		cobra.CheckErr(err)
+		r, err = fetchData(dataDir, source)
 	} else {
-		_, err := git.PlainClone(dataDir, false, &git.CloneOptions{
-			URL:      "https://github.com/davidmegginson/ourairports-data",
-			Progress: os.Stdout,
-			Depth:    1,
-		})
-		cobra.CheckErr(err)
+		r, err = cloneData(dataDir, source)
+	}
+	if err != nil {
+		return err
+	}
+
+	branch, target, err := resolveTarget(r, source)
+	if err != nil && source.Ref != "" && isShallow(r) {
+		// A pinned commit may be older than the shallow history: clone it all
+		fmt.Printf("%s not found in the shallow clone, cloning the full history\n", source.Ref)
+		if err := os.RemoveAll(dataDir); err != nil {
+			return fmt.Errorf("failed to remove shallow clone: %w", err)
+		}
+		r, err = cloneData(dataDir, source)
+		if err != nil {
+			return err
+		}
+		branch, target, err = resolveTarget(r, source)
+	}
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to open data worktree: %w", err)
+	}
+
+	// The data directory is only a cache of the remote: discard any local change
+	options := &git.CheckoutOptions{Hash: target, Force: true}
+	if branch != "" {
+		// Follow the branch rather than leaving a detached HEAD on its head commit
+		branchRef := plumbing.NewBranchReferenceName(branch)
+		err = r.Storer.SetReference(plumbing.NewHashReference(branchRef, target))
+		if err != nil {
+			return fmt.Errorf("failed to update branch %s: %w", branch, err)
+		}
+		options = &git.CheckoutOptions{Branch: branchRef, Force: true}
+	}
+
+	err = w.Checkout(options)
+	if err != nil {
+		return fmt.Errorf("failed to check out %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// cloneData clones the data repository. Only the latest commit is fetched
+// unless a ref is pinned, as it may be anywhere in the history.
+func cloneData(dataDir string, source Source) (*git.Repository, error) {
+	options := &git.CloneOptions{
+		URL:      source.URL,
+		Progress: os.Stdout,
+		Tags:     git.AllTags,
+	}
+	if source.Ref == "" {
+		options.Depth = 1
+	}
+	if source.Branch != "" {
+		options.ReferenceName = plumbing.NewBranchReferenceName(source.Branch)
+	}
+
+	r, err := git.PlainClone(dataDir, false, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone data repository: %w", err)
+	}
+
+	if source.Branch == "" && source.Ref == "" {
+		// The clone checked out the default branch of the remote: remember it for the updates
+		head, err := r.Head()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD reference: %w", err)
+		}
+		if err := setRemoteDefaultBranch(r, head.Name().Short()); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// fetchData fetches the latest changes of an existing clone,
+// pointing it to the configured remote URL first if it changed
+func fetchData(dataDir string, source Source) (*git.Repository, error) {
+	r, err := git.PlainOpen(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data repository: %w", err)
+	}
+
+	remote, err := r.Remote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data repository remote: %w", err)
+	}
+
+	if urls := remote.Config().URLs; len(urls) == 0 || urls[0] != source.URL {
+		fmt.Printf("Data source changed, now fetching from %s\n", source.URL)
+		if err := r.DeleteRemote("origin"); err != nil {
+			return nil, fmt.Errorf("failed to remove previous data remote: %w", err)
+		}
+		// The default branch of the new remote is not known yet
+		if err := r.Storer.RemoveReference(remoteHead); err != nil {
+			return nil, fmt.Errorf("failed to forget the default branch of the previous data remote: %w", err)
+		}
+		_, err := r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{source.URL}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set data remote: %w", err)
+		}
+	}
+
+	err = r.Fetch(&git.FetchOptions{RemoteName: "origin", Tags: git.AllTags, Force: true})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("failed to fetch data repository: %w", err)
 	}
+
+	return r, nil
+}
+
+// remoteHead is the reference remembering the default branch of the data remote, as `git remote set-head` does
+var remoteHead = plumbing.NewRemoteHEADReferenceName("origin")
+
+// resolveTarget returns the commit to check out: the pinned ref if any, otherwise the head
+// of the followed branch on the remote, along with the name of that branch
+func resolveTarget(r *git.Repository, source Source) (string, plumbing.Hash, error) {
+	if source.Ref != "" {
+		hash, err := r.ResolveRevision(plumbing.Revision(source.Ref))
+		if err != nil {
+			return "", plumbing.ZeroHash, fmt.Errorf("failed to resolve pinned ref %s: %w", source.Ref, err)
+		}
+		return "", *hash, nil
+	}
+
+	branch := source.Branch
+	if branch == "" {
+		var err error
+		branch, err = remoteDefaultBranch(r)
+		if err != nil {
+			return "", plumbing.ZeroHash, err
+		}
+	}
+
+	ref, err := r.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to find branch %s on the data remote: %w", branch, err)
+	}
+
+	return branch, ref.Hash(), nil
+}
+
+// remoteDefaultBranch returns the branch the HEAD of the remote points to. It is remembered
+// in remoteHead, and only asked to the remote when unknown, e.g. in clones made by older versions.
+func remoteDefaultBranch(r *git.Repository) (string, error) {
+	if head, err := r.Storer.Reference(remoteHead); err == nil && head.Type() == plumbing.SymbolicReference {
+		return strings.TrimPrefix(head.Target().String(), "refs/remotes/origin/"), nil
+	}
+
+	remote, err := r.Remote("origin")
+	if err != nil {
+		return "", fmt.Errorf("failed to read data repository remote: %w", err)
+	}
+
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list data remote references: %w", err)
+	}
+
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			branch := ref.Target().Short()
+			if err := setRemoteDefaultBranch(r, branch); err != nil {
+				return "", err
+			}
+			return branch, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to find the default branch of the data remote")
+}
+
+// setRemoteDefaultBranch remembers the default branch of the data remote in remoteHead
+func setRemoteDefaultBranch(r *git.Repository, branch string) error {
+	ref := plumbing.NewSymbolicReference(remoteHead, plumbing.NewRemoteReferenceName("origin", branch))
+	if err := r.Storer.SetReference(ref); err != nil {
+		return fmt.Errorf("failed to record the default branch of the data remote: %w", err)
+	}
+	return nil
+}
+
+// isShallow tells whether the repository holds a truncated history
+func isShallow(r *git.Repository) bool {
+	shallows, err := r.Storer.Shallow()
+	return err == nil && len(shallows) > 0
+}
+
+// HeadCommit returns the hash of the commit currently checked out in the data directory
+func HeadCommit() (string, error) {
+	r, err := openDataRepository()
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := r.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD reference: %w", err)
+	}
+
+	return ref.Hash().String(), nil
+}
+
+// CommitsBetween returns the commits reachable from to but not from from, newest first.
+// The list is empty when from is unknown, e.g. outside of a shallow clone's history.
+func CommitsBetween(from string, to string) ([]*object.Commit, error) {
+	r, err := openDataRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.Log(&git.LogOptions{From: plumbing.NewHash(to)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data repository log: %w", err)
+	}
+	defer iter.Close()
+
+	var commits []*object.Commit
+	found := false
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash.String() == from {
+			found = true
+			return storer.ErrStop
+		}
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return nil, fmt.Errorf("failed to walk data repository log: %w", err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return commits, nil
+}
+
+func openDataRepository() (*git.Repository, error) {
+	r, err := git.PlainOpen(DataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data repository: %w", err)
+	}
+
+	return r, nil
 }
@@ -1,10 +1,16 @@
 package server
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+
+	"ask/service"
+
+	"github.com/gorilla/mux"
 )
 
 func (s *Server) airportSearchHandler(w http.ResponseWriter, r *http.Request) {
@@ -13,131 +19,133 @@ func (s *Server) airportSearchHandler(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
 	name := r.URL.Query().Get("name")
 	country := r.URL.Query().Get("country")
+	frequenciesStr := r.URL.Query().Get("frequencies")
 
 	// Validate that name parameter is provided
 	if name == "" {
-		http.Error(w, "Name parameter is required", http.StatusBadRequest)
+		writeError(w, "Name parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	// Sanitize parameters - only accept letters, spaces, hyphens, apostrophes
-	if !isValidSearchParameter(name) {
-		http.Error(w, "Invalid name parameter - only letters, spaces, hyphens, and apostrophes are allowed", http.StatusBadRequest)
+	// Sanitize parameters - only accept letters, digits, spaces, hyphens, apostrophes
+	if !service.IsValidSearchText(name) {
+		writeError(w, "Invalid name parameter - only letters, digits, spaces, hyphens, and apostrophes are allowed", http.StatusBadRequest)
 		return
 	}
 
-	if country != "" && !isValidCountryCode(country) {
-		http.Error(w, "Invalid country parameter - only letters are allowed", http.StatusBadRequest)
+	if country != "" && !service.IsValidCountryCode(country) {
+		writeError(w, "Invalid country parameter - only letters are allowed", http.StatusBadRequest)
 		return
 	}
 
-	// Build SQL query
-	var query strings.Builder
-	var args []interface{}
+	sortKey, descending, ok := parseSort(r, "name", "elevation", "type")
+	if !ok {
+		writeError(w, "Invalid sort parameter - must be name, elevation or type, prefixed by - for a descending order", http.StatusBadRequest)
+		return
+	}
 
-	query.WriteString("SELECT id, ident, type, name, latitude_deg, longitude_deg, COALESCE(NULLIF(elevation_ft, ''), 0) as elevation_ft, continent, iso_country, iso_region, municipality, scheduled_service, icao_code, iata_code, gps_code, local_code, home_link, wikipedia_link, keywords FROM airports WHERE LOWER(name) LIKE LOWER(?)")
-	args = append(args, "%"+name+"%")
+	page, ok := s.parsePage(w, r)
+	if !ok {
+		return
+	}
 
-	if country != "" {
-		query.WriteString(" AND LOWER(iso_country) = LOWER(?)")
-		args = append(args, country)
+	includeFrequencies := false
+	if frequenciesStr != "" {
+		var err error
+		includeFrequencies, err = strconv.ParseBool(frequenciesStr)
+		if err != nil {
+			writeError(w, "Invalid frequencies parameter - must be true or false", http.StatusBadRequest)
+			return
+		}
 	}
 
-	// Execute query
-	rows, err := s.db.Query(query.String(), args...)
+	airports, err := s.store().SearchAirports(name, country)
 	if err != nil {
-		http.Error(w, "Database query failed", http.StatusInternalServerError)
+		writeError(w, "Database query failed", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var airports []Airport
-	for rows.Next() {
-		var (
-			id               sql.NullInt64
-			ident            sql.NullString
-			airportType      sql.NullString
-			name             sql.NullString
-			latitudeDeg      sql.NullFloat64
-			longitudeDeg     sql.NullFloat64
-			elevationFt      sql.NullInt64
-			continent        sql.NullString
-			isoCountry       sql.NullString
-			isoRegion        sql.NullString
-			municipality     sql.NullString
-			scheduledService sql.NullString
-			icaoCode         sql.NullString
-			iataCode         sql.NullString
-			gpsCode          sql.NullString
-			localCode        sql.NullString
-			homeLink         sql.NullString
-			wikipediaLink    sql.NullString
-			keywords         sql.NullString
-		)
-
-		err := rows.Scan(
-			&id,
-			&ident,
-			&airportType,
-			&name,
-			&latitudeDeg,
-			&longitudeDeg,
-			&elevationFt,
-			&continent,
-			&isoCountry,
-			&isoRegion,
-			&municipality,
-			&scheduledService,
-			&icaoCode,
-			&iataCode,
-			&gpsCode,
-			&localCode,
-			&homeLink,
-			&wikipediaLink,
-			&keywords,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning database results", http.StatusInternalServerError)
+
+	// Without a sort parameter the airports keep their relevance order
+	if sortKey != "" {
+		slices.SortStableFunc(airports, func(a, b service.Airport) int {
+			return reverseIf(descending, compareAirports(a, b, sortKey))
+		})
+	}
+
+	airports, pagination := paginate(r, page, airports)
+
+	if includeFrequencies {
+		if err := service.NewSQLiteStore(s.database()).AttachFrequencies(airports); err != nil {
+			writeError(w, "Error retrieving frequencies", http.StatusInternalServerError)
 			return
 		}
+	}
 
-		airport := Airport{
-			ID:               int(id.Int64),
-			Ident:            ident.String,
-			Type:             airportType.String,
-			Name:             name.String,
-			LatitudeDeg:      latitudeDeg.Float64,
-			LongitudeDeg:     longitudeDeg.Float64,
-			ElevationFt:      int(elevationFt.Int64),
-			Continent:        continent.String,
-			IsoCountry:       isoCountry.String,
-			IsoRegion:        isoRegion.String,
-			Municipality:     municipality.String,
-			ScheduledService: scheduledService.String,
-			IcaoCode:         icaoCode.String,
-			IataCode:         iataCode.String,
-			GpsCode:          gpsCode.String,
-			LocalCode:        localCode.String,
-			HomeLink:         homeLink.String,
-			WikipediaLink:    wikipediaLink.String,
-			Keywords:         keywords.String,
-		}
+	response := SearchResponse{
+		Airports:   airports,
+		Count:      len(airports),
+		Pagination: pagination,
+	}
 
-		airports = append(airports, airport)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
 	}
+}
 
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Error processing database results", http.StatusInternalServerError)
+// airportCodeHandler serves the airport of the /api/airports/{code} route, with its runways and frequencies
+func (s *Server) airportCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	match, ok := s.resolveAirport(w, mux.Vars(r)["code"], "Airport")
+	if !ok {
 		return
 	}
 
-	response := SearchResponse{
-		Airports: airports,
-		Count:    len(airports),
+	airports := []service.Airport{match.Airport}
+	if err := service.NewSQLiteStore(s.database()).AttachFrequencies(airports); err != nil {
+		writeError(w, "Error retrieving frequencies", http.StatusInternalServerError)
+		return
+	}
+
+	response := AirportResponse{
+		Airport: airports[0],
+		Match:   match.Kind,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 }
+
+// resolveAirport resolves an airport code of any kind given in a request, and writes the error response
+// when the code is invalid, unknown or matches several airports. The label names the airport in the
+// error messages, e.g. "Departure airport".
+func (s *Server) resolveAirport(w http.ResponseWriter, code string, label string) (*service.CodeMatch, bool) {
+	if !service.IsValidAirportCode(code) {
+		writeError(w, "Invalid "+strings.ToLower(label)+" code - must be 1 to 10 letters, digits or hyphens", http.StatusBadRequest)
+		return nil, false
+	}
+
+	match, err := s.store().ResolveCode(code)
+	if err == nil {
+		return match, true
+	}
+
+	var ambiguous *service.AmbiguousCodeError
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		writeError(w, label+" not found", http.StatusNotFound)
+	case errors.As(err, &ambiguous):
+		// Let the client pick one of the candidates
+		writeErrorResponse(w, http.StatusConflict, AmbiguousCodeResponse{
+			Error:      ambiguous.Error(),
+			Code:       ambiguous.Code,
+			Candidates: ambiguous.Candidates,
+		})
+	default:
+		writeError(w, "Error retrieving "+strings.ToLower(label), http.StatusInternalServerError)
+	}
+	return nil, false
+}
@@ -1,65 +1,139 @@
 package server
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+
+	"ask/service"
+
+	"github.com/gorilla/mux"
 )
 
 func (s *Server) countryListHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Query to get all countries from the countries table
-	query := "SELECT id, code, name, continent, wikipedia_link, keywords FROM countries ORDER BY name"
+	page, ok := s.parsePage(w, r)
+	if !ok {
+		return
+	}
 
-	rows, err := s.db.Query(query)
+	countries, err := s.store().Countries()
 	if err != nil {
-		http.Error(w, "Database query failed", http.StatusInternalServerError)
+		writeError(w, "Database query failed", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var countries []Country
-	for rows.Next() {
-		var (
-			id            sql.NullInt64
-			code          sql.NullString
-			name          sql.NullString
-			continent     sql.NullString
-			wikipediaLink sql.NullString
-			keywords      sql.NullString
-		)
-
-		err := rows.Scan(&id, &code, &name, &continent, &wikipediaLink, &keywords)
-		if err != nil {
-			http.Error(w, "Error scanning database results", http.StatusInternalServerError)
-			return
-		}
-
-		country := Country{
-			ID:            int(id.Int64),
-			Code:          code.String,
-			Name:          name.String,
-			Continent:     continent.String,
-			WikipediaLink: wikipediaLink.String,
-			Keywords:      keywords.String,
-		}
-
-		countries = append(countries, country)
-	}
-
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Error processing database results", http.StatusInternalServerError)
+
+	countries, pagination := paginate(r, page, countries)
+
+	response := CountryListResponse{
+		Countries:  countries,
+		Count:      len(countries),
+		Pagination: pagination,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
+}
 
-	response := CountryListResponse{
-		Countries: countries,
-		Count:     len(countries),
+// countryCodeHandler serves the country of the /api/countries/{code} route
+func (s *Server) countryCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	country, ok := s.findCountry(w, mux.Vars(r)["code"])
+	if !ok {
+		return
+	}
+
+	response := CountryResponse{
+		Country: *country,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// countryCodeAirportsHandler serves the airports of the country of the /api/countries/{code}/airports route,
+// filtered by the type parameter, sorted by the sort parameter and paginated
+func (s *Server) countryCodeAirportsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	typesStr := r.URL.Query().Get("type")
+	types, validTypes := service.ParseAirportTypes(typesStr)
+	if !validTypes {
+		writeError(w, "Invalid airport type - valid types are: large_airport, medium_airport, small_airport, heliport, seaplane_base, closed, balloonport", http.StatusBadRequest)
+		return
+	}
+
+	sortKey, descending, ok := parseSort(r, "name", "elevation", "type")
+	if !ok {
+		writeError(w, "Invalid sort parameter - must be name, elevation or type, prefixed by - for a descending order", http.StatusBadRequest)
+		return
+	}
+
+	page, ok := s.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	country, ok := s.findCountry(w, mux.Vars(r)["code"])
+	if !ok {
+		return
+	}
+
+	airports, err := s.store().AirportsInCountry(country.Code, types)
+	if err != nil {
+		writeError(w, "Database query failed", http.StatusInternalServerError)
+		return
+	}
+	if airports == nil {
+		airports = []service.Airport{}
+	}
+
+	// The airports come sorted by name
+	if sortKey != "" && (sortKey != "name" || descending) {
+		slices.SortStableFunc(airports, func(a, b service.Airport) int {
+			return reverseIf(descending, compareAirports(a, b, sortKey))
+		})
+	}
+
+	airports, pagination := paginate(r, page, airports)
+
+	response := CountryAirportsResponse{
+		Country:    *country,
+		Airports:   airports,
+		Count:      len(airports),
+		Pagination: pagination,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 }
+
+// findCountry looks up the country having the given ISO code, and writes the error response
+// when the code is invalid or unknown
+func (s *Server) findCountry(w http.ResponseWriter, code string) (*service.Country, bool) {
+	if !service.IsValidCountryCode(code) {
+		writeError(w, "Invalid country code - only letters are allowed", http.StatusBadRequest)
+		return nil, false
+	}
+
+	country, err := s.store().CountryByCode(code)
+	if errors.Is(err, service.ErrNotFound) {
+		writeError(w, "Country not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, "Error retrieving country", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return country, true
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"ask/service"
+)
+
+// databaseCheckInterval is how often the server checks whether the database file was replaced
+const databaseCheckInterval = 5 * time.Second
+
+// databaseCloseDelay is how long a replaced database connection stays open, so that the handlers
+// which got it before the swap can still run their queries on it. It exceeds the write timeout
+// of the server, past which their responses cannot be sent anyway.
+const databaseCloseDelay = time.Minute
+
+// database returns the current database connection
+func (s *Server) database() *sql.DB {
+	return s.db.Load()
+}
+
+// store returns the airport store of the current database connection
+func (s *Server) store() service.AirportStore {
+	return service.NewSQLiteStore(s.database())
+}
+
+// initDatabase opens the database and migrates it to the latest schema
+func (s *Server) initDatabase() error {
+	db, info, err := openDatabase(s.dbPath, service.MigrateDatabase)
+	if err != nil {
+		return err
+	}
+
+	s.db.Store(db)
+	s.dbFile = info
+	return nil
+}
+
+// openDatabase opens the database at dbPath with the given function, and returns it
+// along with the file info used to detect its replacement
+func openDatabase(dbPath string, open func(dbPath string) (*sql.DB, error)) (*sql.DB, os.FileInfo, error) {
+	db, err := open(dbPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, info, nil
+}
+
+// watchDatabase periodically checks whether `ask init` atomically replaced the
+// database file, and reopens it without interrupting the server when it did.
+func (s *Server) watchDatabase() {
+	ticker := time.NewTicker(databaseCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopWatch:
+			return
+		case <-ticker.C:
+			s.reloadDatabaseIfReplaced()
+		}
+	}
+}
+
+// reloadDatabaseIfReplaced swaps the database connection when the file at dbPath
+// is no longer the one that was opened. The old connection is closed after databaseCloseDelay,
+// so that the requests being served when the swap happens can finish their queries on it.
+func (s *Server) reloadDatabaseIfReplaced() {
+	info, err := os.Stat(s.dbPath)
+	if err != nil {
+		// The file is missing or unreadable: keep serving the open database
+		return
+	}
+
+	if os.SameFile(info, s.dbFile) {
+		return
+	}
+
+	// `ask init` migrated the new database before installing it
+	db, info, err := openDatabase(s.dbPath, service.OpenDatabase)
+	if err != nil {
+		log.Printf("Database file was replaced but could not be opened, keeping the current one: %v", err)
+		return
+	}
+
+	old := s.db.Swap(db)
+	s.dbFile = info
+	log.Printf("Database file was replaced, reopened %s", s.dbPath)
+
+	if old != nil {
+		time.AfterFunc(databaseCloseDelay, func() {
+			old.Close()
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeError replies to the request with the error message as a JSON ErrorResponse and the HTTP status code
+func writeError(w http.ResponseWriter, message string, code int) {
+	writeErrorResponse(w, code, ErrorResponse{Error: message})
+}
+
+// writeErrorResponse replies to the request with the JSON error response and the HTTP status code
+func writeErrorResponse(w http.ResponseWriter, code int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
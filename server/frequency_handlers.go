@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ask/service"
+)
+
+func (s *Server) frequenciesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	icao := r.URL.Query().Get("icao")
+
+	if icao == "" {
+		writeError(w, "icao parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidICAOCode(icao) {
+		writeError(w, "Invalid ICAO code - must be 4 letters", http.StatusBadRequest)
+		return
+	}
+
+	airport, err := s.store().AirportByCode(icao)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			writeError(w, "Airport not found", http.StatusNotFound)
+			return
+		}
+		writeError(w, "Error retrieving airport", http.StatusInternalServerError)
+		return
+	}
+
+	airports := []service.Airport{*airport}
+	if err := service.NewSQLiteStore(s.database()).AttachFrequencies(airports); err != nil {
+		writeError(w, "Error retrieving frequencies", http.StatusInternalServerError)
+		return
+	}
+
+	frequencies := airports[0].Frequencies
+	if frequencies == nil {
+		frequencies = []service.Frequency{}
+	}
+	// The frequencies are already listed at the top level of the response
+	airport.Frequencies = nil
+
+	response := FrequenciesResponse{
+		Airport:     *airport,
+		Frequencies: frequencies,
+		Count:       len(frequencies),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
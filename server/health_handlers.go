@@ -13,7 +13,7 @@ func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 }
@@ -27,7 +27,7 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 }
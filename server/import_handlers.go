@@ -3,37 +3,28 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+
+	askdb "ask/db"
+	"ask/service"
 )
 
-func (s *Server) importStatusHandler(w http.ResponseWriter, r *http.Request) {
-	var tables []ImportStatus
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 1000
+	defaultIssuesLimit  = 100
+	maxIssuesLimit      = 10000
+)
 
-	if s.db != nil {
-		query := `SELECT table_name, last_import_date, git_commit_hash, git_commit_date, record_count 
-				  FROM import_status 
-				  ORDER BY table_name`
+func (s *Server) importStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var tables []service.ImportStatus
 
-		rows, err := s.db.Query(query)
+	if db := s.database(); db != nil {
+		var err error
+		tables, err = s.store().ImportStatus()
 		if err != nil {
 			// If table doesn't exist, return empty array - don't error
-			tables = []ImportStatus{}
-		} else {
-			defer rows.Close()
-
-			for rows.Next() {
-				var status ImportStatus
-				err := rows.Scan(&status.TableName, &status.LastImportDate, &status.GitCommitHash, &status.GitCommitDate, &status.RecordCount)
-				if err != nil {
-					http.Error(w, "Failed to scan row", http.StatusInternalServerError)
-					return
-				}
-				tables = append(tables, status)
-			}
-
-			if err = rows.Err(); err != nil {
-				http.Error(w, "Row iteration failed", http.StatusInternalServerError)
-				return
-			}
+			tables = []service.ImportStatus{}
 		}
 	}
 
@@ -43,7 +34,87 @@ func (s *Server) importStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) importHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var ok bool
+		limit, ok = isValidLimit(limitStr, maxHistoryLimit)
+		if !ok {
+			writeError(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	airport := r.URL.Query().Get("airport")
+	if airport != "" && !service.IsValidAirportCode(airport) {
+		writeError(w, "Invalid airport parameter", http.StatusBadRequest)
+		return
+	}
+
+	runs := []askdb.ImportRun{}
+	if db := s.database(); db != nil {
+		var err error
+		runs, err = askdb.ImportHistory(db, strings.ToUpper(airport), limit)
+		if err != nil {
+			writeError(w, "Failed to read import history", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := ImportHistoryResponse{
+		Runs:  runs,
+		Count: len(runs),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) importIssuesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultIssuesLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var ok bool
+		limit, ok = isValidLimit(limitStr, maxIssuesLimit)
+		if !ok {
+			writeError(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	check := r.URL.Query().Get("check")
+	if check != "" && !askdb.IsDataQualityCheck(check) {
+		writeError(w, "Invalid check parameter", http.StatusBadRequest)
+		return
+	}
+
+	issues := []askdb.DataIssue{}
+	total := 0
+	if db := s.database(); db != nil {
+		var err error
+		issues, total, err = askdb.ImportIssues(db, check, limit)
+		if err != nil {
+			writeError(w, "Failed to read data quality issues", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := ImportIssuesResponse{
+		Issues: issues,
+		Count:  len(issues),
+		Total:  total,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"ask/service"
+)
+
+const navaidSelectColumns = `SELECT id, filename, ident, name, type,
+	frequency_khz, latitude_deg, longitude_deg, elevation_ft, iso_country,
+	dme_frequency_khz, dme_channel, dme_latitude_deg, dme_longitude_deg, dme_elevation_ft,
+	slaved_variation_deg, magnetic_variation_deg, usageType, power, associated_airport
+	FROM navaids`
+
+func (s *Server) navaidSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ident := r.URL.Query().Get("ident")
+	name := r.URL.Query().Get("name")
+	typesStr := r.URL.Query().Get("type")
+	country := r.URL.Query().Get("country")
+
+	if ident == "" && name == "" {
+		writeError(w, "ident or name parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if ident != "" && !isValidNavaidIdent(ident) {
+		writeError(w, "Invalid ident parameter - must be 1 to 5 letters or digits", http.StatusBadRequest)
+		return
+	}
+
+	if name != "" && !service.IsValidSearchText(name) {
+		writeError(w, "Invalid name parameter - only letters, digits, spaces, hyphens, and apostrophes are allowed", http.StatusBadRequest)
+		return
+	}
+
+	types, validTypes := parseNavaidTypes(typesStr)
+	if !validTypes {
+		writeError(w, "Invalid navaid type - valid types are: VOR, VOR-DME, VORTAC, TACAN, DME, NDB, NDB-DME", http.StatusBadRequest)
+		return
+	}
+
+	if country != "" && !service.IsValidCountryCode(country) {
+		writeError(w, "Invalid country parameter - only letters are allowed", http.StatusBadRequest)
+		return
+	}
+
+	// Build SQL query
+	var conditions []string
+	var args []interface{}
+
+	if ident != "" {
+		conditions = append(conditions, "UPPER(ident) = UPPER(?)")
+		args = append(args, ident)
+	}
+
+	if name != "" {
+		conditions = append(conditions, "LOWER(name) LIKE LOWER(?)")
+		args = append(args, "%"+name+"%")
+	}
+
+	if len(types) > 0 {
+		conditions = append(conditions, "type IN ("+service.SQLPlaceholders(len(types))+")")
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+
+	if country != "" {
+		conditions = append(conditions, "LOWER(iso_country) = LOWER(?)")
+		args = append(args, country)
+	}
+
+	query := navaidSelectColumns + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY ident, name"
+
+	rows, err := s.database().Query(query, args...)
+	if err != nil {
+		writeError(w, "Database query failed", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	navaids := []Navaid{}
+	for rows.Next() {
+		navaid, err := scanNavaid(rows)
+		if err != nil {
+			writeError(w, "Error scanning database results", http.StatusInternalServerError)
+			return
+		}
+		navaids = append(navaids, navaid)
+	}
+
+	if err = rows.Err(); err != nil {
+		writeError(w, "Error processing database results", http.StatusInternalServerError)
+		return
+	}
+
+	response := NavaidSearchResponse{
+		Navaids: navaids,
+		Count:   len(navaids),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) navaidNearbyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	icao := r.URL.Query().Get("icao")
+	rangeStr := r.URL.Query().Get("range")
+
+	if icao == "" {
+		writeError(w, "icao parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if rangeStr == "" {
+		writeError(w, "range parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidICAOCode(icao) {
+		writeError(w, "Invalid ICAO code - must be 4 letters", http.StatusBadRequest)
+		return
+	}
+
+	rangeNM, ok := service.IsValidRange(rangeStr)
+	if !ok {
+		writeError(w, "Invalid range - must be a positive number up to 10800 NM", http.StatusBadRequest)
+		return
+	}
+
+	types, validTypes := parseNavaidTypes(r.URL.Query().Get("type"))
+	if !validTypes {
+		writeError(w, "Invalid navaid type - valid types are: VOR, VOR-DME, VORTAC, TACAN, DME, NDB, NDB-DME", http.StatusBadRequest)
+		return
+	}
+
+	origin, err := s.store().AirportByCode(icao)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			writeError(w, "Airport not found", http.StatusNotFound)
+			return
+		}
+		writeError(w, "Error retrieving airport", http.StatusInternalServerError)
+		return
+	}
+
+	navaids, err := getNavaidsInRange(s.database(), origin, rangeNM, types)
+	if err != nil {
+		writeError(w, "Error querying nearby navaids", http.StatusInternalServerError)
+		return
+	}
+
+	response := NearbyNavaidsResponse{
+		OriginAirport: *origin,
+		RangeNM:       rangeNM,
+		Navaids:       navaids,
+		Count:         len(navaids),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getNavaidsInRange finds all navaids within rangeNM nautical miles of the origin airport,
+// using the same bounding box and haversine filtering as service.SQLiteStore.AirportsInRange.
+// If types is non-empty, only navaids matching those types are returned.
+func getNavaidsInRange(db *sql.DB, origin *service.Airport, rangeNM float64, types []string) ([]NearbyNavaid, error) {
+	bboxClause, args := service.BoundingBoxClause(origin.LatitudeDeg, origin.LongitudeDeg, rangeNM)
+	query := navaidSelectColumns + " WHERE " + bboxClause
+
+	if len(types) > 0 {
+		query += " AND type IN (" + service.SQLPlaceholders(len(types)) + ")"
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []NearbyNavaid{}
+	for rows.Next() {
+		navaid, err := scanNavaid(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		dist := service.Distance(origin.LatitudeDeg, origin.LongitudeDeg, navaid.LatitudeDeg, navaid.LongitudeDeg)
+		if dist <= rangeNM {
+			// Round to 1 decimal place
+			dist = math.Round(dist*10) / 10
+			results = append(results, NearbyNavaid{
+				Navaid:     navaid,
+				DistanceNM: dist,
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceNM < results[j].DistanceNM
+	})
+
+	return results, nil
+}
+
+// scanNavaid scans a single row selected with navaidSelectColumns
+func scanNavaid(rows *sql.Rows) (Navaid, error) {
+	var (
+		id                   sql.NullInt64
+		filename             sql.NullString
+		ident                sql.NullString
+		name                 sql.NullString
+		navaidType           sql.NullString
+		frequencyKhz         sql.NullInt64
+		latitudeDeg          sql.NullFloat64
+		longitudeDeg         sql.NullFloat64
+		elevationFt          sql.NullInt64
+		isoCountry           sql.NullString
+		dmeFrequencyKhz      sql.NullInt64
+		dmeChannel           sql.NullString
+		dmeLatitudeDeg       sql.NullFloat64
+		dmeLongitudeDeg      sql.NullFloat64
+		dmeElevationFt       sql.NullInt64
+		slavedVariationDeg   sql.NullFloat64
+		magneticVariationDeg sql.NullFloat64
+		usageType            sql.NullString
+		power                sql.NullString
+		associatedAirport    sql.NullString
+	)
+
+	err := rows.Scan(
+		&id, &filename, &ident, &name, &navaidType,
+		&frequencyKhz, &latitudeDeg, &longitudeDeg, &elevationFt, &isoCountry,
+		&dmeFrequencyKhz, &dmeChannel, &dmeLatitudeDeg, &dmeLongitudeDeg, &dmeElevationFt,
+		&slavedVariationDeg, &magneticVariationDeg, &usageType, &power, &associatedAirport,
+	)
+	if err != nil {
+		return Navaid{}, err
+	}
+
+	return Navaid{
+		ID:                   int(id.Int64),
+		Filename:             filename.String,
+		Ident:                ident.String,
+		Name:                 name.String,
+		Type:                 navaidType.String,
+		FrequencyKhz:         int(frequencyKhz.Int64),
+		LatitudeDeg:          latitudeDeg.Float64,
+		LongitudeDeg:         longitudeDeg.Float64,
+		ElevationFt:          int(elevationFt.Int64),
+		IsoCountry:           isoCountry.String,
+		DmeFrequencyKhz:      int(dmeFrequencyKhz.Int64),
+		DmeChannel:           dmeChannel.String,
+		DmeLatitudeDeg:       dmeLatitudeDeg.Float64,
+		DmeLongitudeDeg:      dmeLongitudeDeg.Float64,
+		DmeElevationFt:       int(dmeElevationFt.Int64),
+		SlavedVariationDeg:   slavedVariationDeg.Float64,
+		MagneticVariationDeg: magneticVariationDeg.Float64,
+		UsageType:            usageType.String,
+		Power:                power.String,
+		AssociatedAirport:    associatedAirport.String,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultNearestCount is the number of nearest airports returned when none is given
+const defaultNearestCount = 10
+
+// nearestHandler serves the n airports closest to the lat and lon parameters,
+// filtered by the parameters read by parseAirportFilter
+func (s *Server) nearestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	latStr := r.URL.Query().Get("lat")
+	lonStr := r.URL.Query().Get("lon")
+
+	if latStr == "" || lonStr == "" {
+		writeError(w, "lat and lon parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	lat, ok := isValidLatitude(latStr)
+	if !ok {
+		writeError(w, "Invalid lat parameter - must be a number of degrees from -90 to 90", http.StatusBadRequest)
+		return
+	}
+
+	lon, ok := isValidLongitude(lonStr)
+	if !ok {
+		writeError(w, "Invalid lon parameter - must be a number of degrees from -180 to 180", http.StatusBadRequest)
+		return
+	}
+
+	n := min(defaultNearestCount, s.maxResults)
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		n, ok = isValidLimit(nStr, s.maxResults)
+		if !ok {
+			writeError(w, "Invalid n parameter - must be a positive number up to "+strconv.Itoa(s.maxResults), http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter, ok := parseAirportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	airports, err := s.store().NearestAirports(lat, lon, n, filter)
+	if err != nil {
+		writeError(w, "Error querying nearest airports", http.StatusInternalServerError)
+		return
+	}
+
+	response := NearestResponse{
+		Latitude:  lat,
+		Longitude: lon,
+		Airports:  airports,
+		Count:     len(airports),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
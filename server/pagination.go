@@ -0,0 +1,117 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ask/service"
+)
+
+// DefaultMaxResults is the default maximum number of items returned by a list endpoint
+const DefaultMaxResults = 1000
+
+// Pagination describes the page of a list returned by an endpoint: the total number of items,
+// the window of the page and the link to the next page when there is one
+type Pagination struct {
+	Total  int    `json:"total"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Next   string `json:"next,omitempty"`
+}
+
+// page is the window of a list requested with the limit and offset parameters
+type page struct {
+	limit  int
+	offset int
+}
+
+// parsePage reads the limit and offset parameters of the request and writes the error response when they
+// are invalid. The limit defaults to, and cannot exceed, the maximum number of results of the server.
+func (s *Server) parsePage(w http.ResponseWriter, r *http.Request) (page, bool) {
+	p := page{limit: s.maxResults}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, ok := isValidLimit(limitStr, s.maxResults)
+		if !ok {
+			writeError(w, "Invalid limit parameter - must be a positive number up to "+strconv.Itoa(s.maxResults), http.StatusBadRequest)
+			return page{}, false
+		}
+		p.limit = limit
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			writeError(w, "Invalid offset parameter - must be a positive number", http.StatusBadRequest)
+			return page{}, false
+		}
+		p.offset = offset
+	}
+
+	return p, true
+}
+
+// paginate returns the items of the page, and its description with the link to the next page
+func paginate[T any](r *http.Request, p page, items []T) ([]T, Pagination) {
+	total := len(items)
+	start := min(p.offset, total)
+	end := min(start+p.limit, total)
+
+	pagination := Pagination{
+		Total:  total,
+		Offset: p.offset,
+		Limit:  p.limit,
+	}
+
+	if end < total {
+		query := r.URL.Query()
+		query.Set("offset", strconv.Itoa(end))
+		query.Set("limit", strconv.Itoa(p.limit))
+		pagination.Next = r.URL.Path + "?" + query.Encode()
+	}
+
+	return items[start:end], pagination
+}
+
+// parseSort reads the sort parameter of the request, a key among the allowed ones optionally prefixed
+// by "-" for a descending order. It returns an empty key when the parameter is not given.
+func parseSort(r *http.Request, allowed ...string) (key string, descending bool, ok bool) {
+	key = r.URL.Query().Get("sort")
+	if key == "" {
+		return "", false, true
+	}
+
+	key, descending = strings.CutPrefix(key, "-")
+	for _, a := range allowed {
+		if key == a {
+			return key, descending, true
+		}
+	}
+	return "", false, false
+}
+
+// compareAirports compares two airports by the given sort key: name, elevation or type (the largest first).
+// Ties are broken by name.
+func compareAirports(a, b service.Airport, key string) int {
+	var c int
+	switch key {
+	case "elevation":
+		c = cmp.Compare(a.ElevationFt, b.ElevationFt)
+	case "type":
+		c = cmp.Compare(service.TypeRank(a.Type), service.TypeRank(b.Type))
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.Name, b.Name)
+}
+
+// reverseIf reverses the comparison when descending is set
+func reverseIf(descending bool, c int) int {
+	if descending {
+		return -c
+	}
+	return c
+}
@@ -1,70 +1,190 @@
 package server
 
 import (
-	"database/sql"
+	"cmp"
 	"encoding/json"
 	"net/http"
+	"slices"
+
+	"ask/service"
+
+	"github.com/gorilla/mux"
 )
 
+// defaultNearbyRange is the range, in nautical miles, of the nearby airports when none is given
+const defaultNearbyRange = "50"
+
 func (s *Server) reachableHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	icao := r.URL.Query().Get("icao")
+	// Any kind of airport code is accepted, the icao parameter is kept for the existing clients
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		code = r.URL.Query().Get("icao")
+	}
 	rangeStr := r.URL.Query().Get("range")
 
-	if icao == "" {
-		http.Error(w, "icao parameter is required", http.StatusBadRequest)
+	if code == "" {
+		writeError(w, "code parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if rangeStr == "" {
+		writeError(w, "range parameter is required", http.StatusBadRequest)
 		return
 	}
 
+	s.writeReachable(w, r, code, rangeStr)
+}
+
+// airportCodeNearbyHandler serves the airports within range of the airport of the
+// /api/airports/{code}/nearby route, within defaultNearbyRange when no range is given
+func (s *Server) airportCodeNearbyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rangeStr := r.URL.Query().Get("range")
 	if rangeStr == "" {
-		http.Error(w, "range parameter is required", http.StatusBadRequest)
+		rangeStr = defaultNearbyRange
+	}
+
+	s.writeReachable(w, r, mux.Vars(r)["code"], rangeStr)
+}
+
+// writeReachable writes the airports within range of the airport having the given code,
+// filtered by the parameters of the request read by parseAirportFilter
+func (s *Server) writeReachable(w http.ResponseWriter, r *http.Request, code string, rangeStr string) {
+	rangeNM, ok := service.IsValidRange(rangeStr)
+	if !ok {
+		writeError(w, "Invalid range - must be a positive number up to 10800 NM", http.StatusBadRequest)
 		return
 	}
 
-	if !isValidICAOCode(icao) {
-		http.Error(w, "Invalid ICAO code - must be 4 letters", http.StatusBadRequest)
+	filter, ok := parseAirportFilter(w, r)
+	if !ok {
 		return
 	}
 
-	rangeNM, ok := isValidRange(rangeStr)
+	sortKey, descending, ok := parseSort(r, "distance", "name", "elevation", "type")
 	if !ok {
-		http.Error(w, "Invalid range - must be a positive number up to 10800 NM", http.StatusBadRequest)
+		writeError(w, "Invalid sort parameter - must be distance, name, elevation or type, prefixed by - for a descending order", http.StatusBadRequest)
 		return
 	}
 
-	typesStr := r.URL.Query().Get("type")
-	types, validTypes := parseAirportTypes(typesStr)
-	if !validTypes {
-		http.Error(w, "Invalid airport type - valid types are: large_airport, medium_airport, small_airport, heliport, seaplane_base, closed, balloonport", http.StatusBadRequest)
+	page, ok := s.parsePage(w, r)
+	if !ok {
 		return
 	}
 
-	origin, err := s.getAirportByICAO(icao)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Airport not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error retrieving airport", http.StatusInternalServerError)
+	origin, ok := s.resolveAirport(w, code, "Airport")
+	if !ok {
 		return
 	}
 
-	airports, err := s.getAirportsInRange(origin, rangeNM, types)
+	airports, err := s.store().AirportsInRange(&origin.Airport, rangeNM, filter)
 	if err != nil {
-		http.Error(w, "Error querying reachable airports", http.StatusInternalServerError)
+		writeError(w, "Error querying reachable airports", http.StatusInternalServerError)
 		return
 	}
 
+	// The airports come closest first
+	if sortKey != "" && (sortKey != "distance" || descending) {
+		slices.SortStableFunc(airports, func(a, b service.ReachableAirport) int {
+			if sortKey == "distance" {
+				return reverseIf(descending, cmp.Compare(a.DistanceNM, b.DistanceNM))
+			}
+			return reverseIf(descending, compareAirports(a.Airport, b.Airport, sortKey))
+		})
+	}
+
+	airports, pagination := paginate(r, page, airports)
+
 	response := ReachableResponse{
-		OriginAirport: *origin,
+		OriginAirport: origin.Airport,
+		OriginMatch:   origin.Kind,
 		RangeNM:       rangeNM,
 		Airports:      airports,
 		Count:         len(airports),
+		Pagination:    pagination,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 }
+
+// parseAirportFilter reads the airport filter parameters of the request, and writes the error response when
+// one is invalid: type, country and continent (comma-separated lists to keep), exclude_country and
+// exclude_continent (to drop), scheduled_service=yes, min_elevation and max_elevation in feet,
+// and min_runway, the minimum length in feet of an open runway
+func parseAirportFilter(w http.ResponseWriter, r *http.Request) (service.AirportFilter, bool) {
+	query := r.URL.Query()
+	var filter service.AirportFilter
+	var ok bool
+
+	filter.Types, ok = service.ParseAirportTypes(query.Get("type"))
+	if !ok {
+		writeError(w, "Invalid airport type - valid types are: large_airport, medium_airport, small_airport, heliport, seaplane_base, closed, balloonport", http.StatusBadRequest)
+		return filter, false
+	}
+
+	filter.Countries, ok = service.ParseCountryCodes(query.Get("country"))
+	if !ok {
+		writeError(w, "Invalid country parameter - must be comma-separated country codes", http.StatusBadRequest)
+		return filter, false
+	}
+
+	filter.ExcludedCountries, ok = service.ParseCountryCodes(query.Get("exclude_country"))
+	if !ok {
+		writeError(w, "Invalid exclude_country parameter - must be comma-separated country codes", http.StatusBadRequest)
+		return filter, false
+	}
+
+	filter.Continents, ok = service.ParseContinents(query.Get("continent"))
+	if !ok {
+		writeError(w, "Invalid continent parameter - valid continents are: AF, AN, AS, EU, NA, OC, SA", http.StatusBadRequest)
+		return filter, false
+	}
+
+	filter.ExcludedContinents, ok = service.ParseContinents(query.Get("exclude_continent"))
+	if !ok {
+		writeError(w, "Invalid exclude_continent parameter - valid continents are: AF, AN, AS, EU, NA, OC, SA", http.StatusBadRequest)
+		return filter, false
+	}
+
+	if scheduled := query.Get("scheduled_service"); scheduled != "" {
+		if scheduled != "yes" {
+			writeError(w, "Invalid scheduled_service parameter - must be yes", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.ScheduledService = true
+	}
+
+	if elevationStr := query.Get("min_elevation"); elevationStr != "" {
+		elevation, ok := isValidElevation(elevationStr)
+		if !ok {
+			writeError(w, "Invalid min_elevation parameter - must be a whole number of feet", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.MinElevationFt = &elevation
+	}
+
+	if elevationStr := query.Get("max_elevation"); elevationStr != "" {
+		elevation, ok := isValidElevation(elevationStr)
+		if !ok {
+			writeError(w, "Invalid max_elevation parameter - must be a whole number of feet", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.MaxElevationFt = &elevation
+	}
+
+	if lengthStr := query.Get("min_runway"); lengthStr != "" {
+		filter.MinRunwayFt, ok = isValidRunwayLength(lengthStr)
+		if !ok {
+			writeError(w, "Invalid min_runway parameter - must be a positive number of feet", http.StatusBadRequest)
+			return filter, false
+		}
+	}
+
+	return filter, true
+}
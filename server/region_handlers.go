@@ -0,0 +1,86 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"ask/service"
+)
+
+func (s *Server) regionListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	country := r.URL.Query().Get("country")
+
+	if country != "" && !service.IsValidCountryCode(country) {
+		writeError(w, "Invalid country parameter - only letters are allowed", http.StatusBadRequest)
+		return
+	}
+
+	// Query to get the regions, optionally restricted to a single country
+	query := "SELECT id, code, local_code, name, continent, iso_country, wikipedia_link, keywords FROM regions"
+	var args []interface{}
+
+	if country != "" {
+		query += " WHERE LOWER(iso_country) = LOWER(?)"
+		args = append(args, country)
+	}
+
+	query += " ORDER BY iso_country, name"
+
+	rows, err := s.database().Query(query, args...)
+	if err != nil {
+		writeError(w, "Database query failed", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var regions []Region
+	for rows.Next() {
+		var (
+			id            sql.NullInt64
+			code          sql.NullString
+			localCode     sql.NullString
+			name          sql.NullString
+			continent     sql.NullString
+			isoCountry    sql.NullString
+			wikipediaLink sql.NullString
+			keywords      sql.NullString
+		)
+
+		err := rows.Scan(&id, &code, &localCode, &name, &continent, &isoCountry, &wikipediaLink, &keywords)
+		if err != nil {
+			writeError(w, "Error scanning database results", http.StatusInternalServerError)
+			return
+		}
+
+		region := Region{
+			ID:            int(id.Int64),
+			Code:          code.String,
+			LocalCode:     localCode.String,
+			Name:          name.String,
+			Continent:     continent.String,
+			IsoCountry:    isoCountry.String,
+			WikipediaLink: wikipediaLink.String,
+			Keywords:      keywords.String,
+		}
+
+		regions = append(regions, region)
+	}
+
+	if err = rows.Err(); err != nil {
+		writeError(w, "Error processing database results", http.StatusInternalServerError)
+		return
+	}
+
+	response := RegionListResponse{
+		Regions: regions,
+		Count:   len(regions),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -5,26 +5,41 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
-	"path/filepath"
+	"os"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/spf13/viper"
 )
 
 type Server struct {
 	port   int
 	router *mux.Router
 	server *http.Server
-	db     *sql.DB
+
+	// maxResults is the maximum number of items returned by the list endpoints
+	maxResults int
+
+	// db is swapped when `ask init` replaces the database file, see watchDatabase
+	db        atomic.Pointer[sql.DB]
+	dbPath    string
+	dbFile    os.FileInfo
+	stopWatch chan struct{}
 }
 
-func NewServer(port int) *Server {
+func NewServer(port int, dbPath string, maxResults int) *Server {
+	if maxResults <= 0 {
+		maxResults = DefaultMaxResults
+	}
+
 	s := &Server{
-		port:   port,
-		router: mux.NewRouter(),
+		port:       port,
+		router:     mux.NewRouter(),
+		maxResults: maxResults,
+		dbPath:     dbPath,
+		stopWatch:  make(chan struct{}),
 	}
 
 	// Initialize database connection
@@ -32,6 +47,8 @@ func NewServer(port int) *Server {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	go s.watchDatabase()
+
 	s.setupRoutes()
 
 	s.server = &http.Server{
@@ -53,8 +70,24 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/api/airport/distance", s.distanceHandler).Methods("GET")
 	s.router.HandleFunc("/api/airport/time", s.airportTimeHandler).Methods("GET")
 	s.router.HandleFunc("/api/airport/reachable", s.reachableHandler).Methods("GET")
+	s.router.HandleFunc("/api/airport/runways", s.runwaysHandler).Methods("GET")
+	s.router.HandleFunc("/api/airport/frequencies", s.frequenciesHandler).Methods("GET")
+	s.router.HandleFunc("/api/nearest", s.nearestHandler).Methods("GET")
+	s.router.HandleFunc("/api/navaid/search", s.navaidSearchHandler).Methods("GET")
+	s.router.HandleFunc("/api/navaid/nearby", s.navaidNearbyHandler).Methods("GET")
 	s.router.HandleFunc("/api/country", s.countryListHandler).Methods("GET")
+	s.router.HandleFunc("/api/region", s.regionListHandler).Methods("GET")
 	s.router.HandleFunc("/api/import/status", s.importStatusHandler).Methods("GET")
+	s.router.HandleFunc("/api/import/history", s.importHistoryHandler).Methods("GET")
+	s.router.HandleFunc("/api/import/issues", s.importIssuesHandler).Methods("GET")
+
+	// Resource routes
+	s.router.HandleFunc("/api/airports/{code}", s.airportCodeHandler).Methods("GET")
+	s.router.HandleFunc("/api/airports/{code}/time", s.airportCodeTimeHandler).Methods("GET")
+	s.router.HandleFunc("/api/airports/{code}/nearby", s.airportCodeNearbyHandler).Methods("GET")
+	s.router.HandleFunc("/api/countries", s.countryListHandler).Methods("GET")
+	s.router.HandleFunc("/api/countries/{code}", s.countryCodeHandler).Methods("GET")
+	s.router.HandleFunc("/api/countries/{code}/airports", s.countryCodeAirportsHandler).Methods("GET")
 
 	// Static files
 	s.router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
@@ -71,29 +104,12 @@ func (s *Server) Start() error {
 	return s.server.ListenAndServe()
 }
 
-func (s *Server) initDatabase() error {
-	repoDir := viper.GetString("repository")
-	dbDir := filepath.Join(repoDir, viper.GetString("db"))
-	dbPath := filepath.Join(dbDir, "ask.db")
-
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return err
-	}
-
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		return err
-	}
-
-	s.db = db
-	return nil
-}
-
 func (s *Server) Stop(ctx context.Context) error {
 	log.Println("Stopping server...")
-	if s.db != nil {
-		s.db.Close()
+	close(s.stopWatch)
+	err := s.server.Shutdown(ctx)
+	if db := s.database(); db != nil {
+		db.Close()
 	}
-	return s.server.Shutdown(ctx)
+	return err
 }
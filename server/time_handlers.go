@@ -1,80 +1,53 @@
 package server
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
-	"time"
 
-	"github.com/ringsaturn/tzf"
-	pb "github.com/ringsaturn/tzf/gen/go/tzf/v1"
-	tzfrellite "github.com/ringsaturn/tzf-rel-lite"
-	"google.golang.org/protobuf/proto"
-)
-
-var finder tzf.F
+	"ask/service"
 
-func init() {
-	input := &pb.CompressedTimezones{}
-	if err := proto.Unmarshal(tzfrellite.LiteCompressData, input); err != nil {
-		panic("failed to unmarshal compressed timezone data: " + err.Error())
-	}
-	var err error
-	finder, err = tzf.NewFinderFromCompressed(input)
-	if err != nil {
-		panic("failed to initialize timezone finder: " + err.Error())
-	}
-}
+	"github.com/gorilla/mux"
+)
 
 func (s *Server) airportTimeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	icao := r.URL.Query().Get("icao")
-
-	if icao == "" {
-		http.Error(w, "icao parameter is required", http.StatusBadRequest)
-		return
+	// Any kind of airport code is accepted, the icao parameter is kept for the existing clients
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		code = r.URL.Query().Get("icao")
 	}
 
-	if !isValidICAOCode(icao) {
-		http.Error(w, "Invalid ICAO code - must be 4 letters", http.StatusBadRequest)
+	if code == "" {
+		writeError(w, "code parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	airport, err := s.getAirportByICAO(icao)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Airport not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error retrieving airport", http.StatusInternalServerError)
-		return
-	}
+	s.writeAirportTime(w, code)
+}
 
-	timezoneName := finder.GetTimezoneName(airport.LongitudeDeg, airport.LatitudeDeg)
-	if timezoneName == "" {
-		http.Error(w, "Could not determine timezone for airport location", http.StatusInternalServerError)
+// airportCodeTimeHandler serves the local time of the airport of the /api/airports/{code}/time route
+func (s *Server) airportCodeTimeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.writeAirportTime(w, mux.Vars(r)["code"])
+}
+
+// writeAirportTime writes the local time of the airport having the given code
+func (s *Server) writeAirportTime(w http.ResponseWriter, code string) {
+	match, ok := s.resolveAirport(w, code, "Airport")
+	if !ok {
 		return
 	}
 
-	loc, err := time.LoadLocation(timezoneName)
+	response, err := service.LocalTime(&match.Airport)
 	if err != nil {
-		http.Error(w, "Error loading timezone", http.StatusInternalServerError)
+		writeError(w, "Error determining the airport local time", http.StatusInternalServerError)
 		return
 	}
-
-	now := time.Now().In(loc)
-
-	response := AirportTimeResponse{
-		ICAO:      airport.IcaoCode,
-		Name:      airport.Name,
-		Timezone:  timezoneName,
-		LocalTime: now.Format(time.RFC3339),
-		UTCOffset: now.Format("-07:00"),
-	}
+	response.Match = match.Kind
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 }
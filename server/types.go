@@ -1,5 +1,10 @@
 package server
 
+import (
+	askdb "ask/db"
+	"ask/service"
+)
+
 const VERSION = "v0.2.2"
 
 type VersionResponse struct {
@@ -10,65 +15,115 @@ type HealthResponse struct {
 	Status string `json:"status"`
 }
 
-type Airport struct {
-	ID               int     `json:"id"`
-	Ident            string  `json:"ident"`
-	Type             string  `json:"type"`
-	Name             string  `json:"name"`
-	LatitudeDeg      float64 `json:"latitude_deg"`
-	LongitudeDeg     float64 `json:"longitude_deg"`
-	ElevationFt      int     `json:"elevation_ft"`
-	Continent        string  `json:"continent"`
-	IsoCountry       string  `json:"iso_country"`
-	IsoRegion        string  `json:"iso_region"`
-	Municipality     string  `json:"municipality"`
-	ScheduledService string  `json:"scheduled_service"`
-	IcaoCode         string  `json:"icao_code"`
-	IataCode         string  `json:"iata_code"`
-	GpsCode          string  `json:"gps_code"`
-	LocalCode        string  `json:"local_code"`
-	HomeLink         string  `json:"home_link"`
-	WikipediaLink    string  `json:"wikipedia_link"`
-	Keywords         string  `json:"keywords"`
-}
-
-type Country struct {
+type SearchResponse struct {
+	Airports []service.Airport `json:"airports"`
+	Count    int               `json:"count"`
+	Pagination
+}
+
+type AirportResponse struct {
+	Airport service.Airport  `json:"airport"`
+	Match   service.CodeKind `json:"match"`
+}
+
+type RunwaysResponse struct {
+	Airport service.Airport  `json:"airport"`
+	Runways []service.Runway `json:"runways"`
+	Count   int              `json:"count"`
+}
+
+type Navaid struct {
+	ID                   int     `json:"id"`
+	Filename             string  `json:"filename"`
+	Ident                string  `json:"ident"`
+	Name                 string  `json:"name"`
+	Type                 string  `json:"type"`
+	FrequencyKhz         int     `json:"frequency_khz"`
+	LatitudeDeg          float64 `json:"latitude_deg"`
+	LongitudeDeg         float64 `json:"longitude_deg"`
+	ElevationFt          int     `json:"elevation_ft"`
+	IsoCountry           string  `json:"iso_country"`
+	DmeFrequencyKhz      int     `json:"dme_frequency_khz"`
+	DmeChannel           string  `json:"dme_channel"`
+	DmeLatitudeDeg       float64 `json:"dme_latitude_deg"`
+	DmeLongitudeDeg      float64 `json:"dme_longitude_deg"`
+	DmeElevationFt       int     `json:"dme_elevation_ft"`
+	SlavedVariationDeg   float64 `json:"slaved_variation_deg"`
+	MagneticVariationDeg float64 `json:"magnetic_variation_deg"`
+	UsageType            string  `json:"usage_type"`
+	Power                string  `json:"power"`
+	AssociatedAirport    string  `json:"associated_airport"`
+}
+
+type NavaidSearchResponse struct {
+	Navaids []Navaid `json:"navaids"`
+	Count   int      `json:"count"`
+}
+
+type NearbyNavaid struct {
+	Navaid     Navaid  `json:"navaid"`
+	DistanceNM float64 `json:"distance_nm"`
+}
+
+type NearbyNavaidsResponse struct {
+	OriginAirport service.Airport `json:"origin_airport"`
+	RangeNM       float64         `json:"range_nm"`
+	Navaids       []NearbyNavaid  `json:"navaids"`
+	Count         int             `json:"count"`
+}
+
+type FrequenciesResponse struct {
+	Airport     service.Airport     `json:"airport"`
+	Frequencies []service.Frequency `json:"frequencies"`
+	Count       int                 `json:"count"`
+}
+
+type Region struct {
 	ID            int    `json:"id"`
 	Code          string `json:"code"`
+	LocalCode     string `json:"local_code"`
 	Name          string `json:"name"`
 	Continent     string `json:"continent"`
+	IsoCountry    string `json:"iso_country"`
 	WikipediaLink string `json:"wikipedia_link"`
 	Keywords      string `json:"keywords"`
 }
 
-type SearchResponse struct {
-	Airports []Airport `json:"airports"`
-	Count    int       `json:"count"`
+type RegionListResponse struct {
+	Regions []Region `json:"regions"`
+	Count   int      `json:"count"`
 }
 
 type CountryListResponse struct {
-	Countries []Country `json:"countries"`
-	Count     int       `json:"count"`
+	Countries []service.Country `json:"countries"`
+	Count     int               `json:"count"`
+	Pagination
 }
 
-type ImportStatus struct {
-	TableName      string `json:"table_name"`
-	LastImportDate string `json:"last_import_date"`
-	GitCommitHash  string `json:"git_commit_hash"`
-	GitCommitDate  string `json:"git_commit_date"`
-	RecordCount    int    `json:"record_count"`
+type CountryResponse struct {
+	Country service.Country `json:"country"`
+}
+
+type CountryAirportsResponse struct {
+	Country  service.Country   `json:"country"`
+	Airports []service.Airport `json:"airports"`
+	Count    int               `json:"count"`
+	Pagination
 }
 
 type ImportStatusResponse struct {
-	Tables []ImportStatus `json:"tables"`
+	Tables []service.ImportStatus `json:"tables"`
+}
+
+type ImportIssuesResponse struct {
+	Issues []askdb.DataIssue `json:"issues"`
+	Count  int               `json:"count"`
+	Total  int               `json:"total"`
 }
 
-type AirportTimeResponse struct {
-	ICAO      string `json:"icao"`
-	Name      string `json:"name"`
-	Timezone  string `json:"timezone"`
-	LocalTime string `json:"local_time"`
-	UTCOffset string `json:"utc_offset"`
+type ImportHistoryResponse struct {
+	Runs  []askdb.ImportRun `json:"runs"`
+	Count int               `json:"count"`
 }
 
 type DistanceRequest struct {
@@ -77,19 +132,35 @@ type DistanceRequest struct {
 }
 
 type DistanceResponse struct {
-	DepartureAirport   Airport `json:"departure_airport"`
-	DestinationAirport Airport `json:"destination_airport"`
-	DistanceNM         float64 `json:"distance_nm"`
+	DepartureAirport   service.Airport  `json:"departure_airport"`
+	DepartureMatch     service.CodeKind `json:"departure_match,omitempty"`
+	DestinationAirport service.Airport  `json:"destination_airport"`
+	DestinationMatch   service.CodeKind `json:"destination_match,omitempty"`
+	DistanceNM         float64          `json:"distance_nm"`
 }
 
-type ReachableAirport struct {
-	Airport    Airport `json:"airport"`
-	DistanceNM float64 `json:"distance_nm"`
+type ReachableResponse struct {
+	OriginAirport service.Airport            `json:"origin_airport"`
+	OriginMatch   service.CodeKind           `json:"origin_match,omitempty"`
+	RangeNM       float64                    `json:"range_nm"`
+	Airports      []service.ReachableAirport `json:"airports"`
+	Count         int                        `json:"count"`
+	Pagination
 }
 
-type ReachableResponse struct {
-	OriginAirport Airport            `json:"origin_airport"`
-	RangeNM       float64            `json:"range_nm"`
-	Airports      []ReachableAirport `json:"airports"`
-	Count         int                `json:"count"`
+type NearestResponse struct {
+	Latitude  float64                  `json:"latitude"`
+	Longitude float64                  `json:"longitude"`
+	Airports  []service.NearestAirport `json:"airports"`
+	Count     int                      `json:"count"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type AmbiguousCodeResponse struct {
+	Error      string              `json:"error"`
+	Code       string              `json:"code"`
+	Candidates []service.CodeMatch `json:"candidates"`
 }
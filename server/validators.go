@@ -6,35 +6,30 @@ import (
 	"strings"
 )
 
-var validAirportTypes = map[string]bool{
-	"large_airport":  true,
-	"medium_airport": true,
-	"small_airport":  true,
-	"heliport":       true,
-	"seaplane_base":  true,
-	"closed":         true,
-	"balloonport":    true,
+var validNavaidTypes = map[string]bool{
+	"VOR":     true,
+	"VOR-DME": true,
+	"VORTAC":  true,
+	"TACAN":   true,
+	"DME":     true,
+	"NDB":     true,
+	"NDB-DME": true,
 }
 
-// isValidAirportType checks if the given type string is a known airport type
-func isValidAirportType(t string) bool {
-	return validAirportTypes[t]
-}
-
-// parseAirportTypes splits a comma-separated type string, trims whitespace,
-// validates each type, and returns the list. Returns false if any type is invalid.
-func parseAirportTypes(typesStr string) ([]string, bool) {
+// parseNavaidTypes splits a comma-separated navaid type string, trims whitespace,
+// upper-cases and validates each type. Returns false if any type is invalid.
+func parseNavaidTypes(typesStr string) ([]string, bool) {
 	if typesStr == "" {
 		return nil, true
 	}
 	parts := strings.Split(typesStr, ",")
 	types := make([]string, 0, len(parts))
 	for _, p := range parts {
-		t := strings.TrimSpace(p)
+		t := strings.ToUpper(strings.TrimSpace(p))
 		if t == "" {
 			continue
 		}
-		if !isValidAirportType(t) {
+		if !validNavaidTypes[t] {
 			return nil, false
 		}
 		types = append(types, t)
@@ -42,17 +37,19 @@ func parseAirportTypes(typesStr string) ([]string, bool) {
 	return types, true
 }
 
-// isValidSearchParameter validates that the search parameter contains only allowed characters
-func isValidSearchParameter(param string) bool {
-	// Allow letters, spaces, hyphens, apostrophes, and common punctuation for airport names
-	matched, _ := regexp.MatchString(`^[a-zA-Z\s\-'\.]+$`, param)
+// isValidNavaidIdent validates that the navaid ident is 1 to 5 letters or digits
+func isValidNavaidIdent(ident string) bool {
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9]{1,5}$`, ident)
 	return matched
 }
 
-// isValidCountryCode validates that the country code contains only letters
-func isValidCountryCode(code string) bool {
-	matched, _ := regexp.MatchString(`^[a-zA-Z]+$`, code)
-	return matched
+// isValidLimit validates that the limit string is a positive integer up to max
+func isValidLimit(limitStr string, max int) (int, bool) {
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 || limit > max {
+		return 0, false
+	}
+	return limit, true
 }
 
 // isValidICAOCode validates that the ICAO code is exactly 4 letters
@@ -61,14 +58,38 @@ func isValidICAOCode(code string) bool {
 	return matched
 }
 
-// isValidRange validates that the range string is a positive number up to 10800 NM (half Earth circumference)
-func isValidRange(rangeStr string) (float64, bool) {
-	rangeNM, err := strconv.ParseFloat(rangeStr, 64)
+// isValidElevation validates that the elevation string is a whole number of feet
+func isValidElevation(elevationStr string) (int, bool) {
+	elevation, err := strconv.Atoi(elevationStr)
 	if err != nil {
 		return 0, false
 	}
-	if rangeNM <= 0 || rangeNM > 10800 {
+	return elevation, true
+}
+
+// isValidRunwayLength validates that the runway length string is a positive whole number of feet
+func isValidRunwayLength(lengthStr string) (int, bool) {
+	length, err := strconv.Atoi(lengthStr)
+	if err != nil || length <= 0 {
+		return 0, false
+	}
+	return length, true
+}
+
+// isValidLatitude validates that the latitude string is a number of degrees from -90 to 90
+func isValidLatitude(latStr string) (float64, bool) {
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil || !(lat >= -90 && lat <= 90) {
+		return 0, false
+	}
+	return lat, true
+}
+
+// isValidLongitude validates that the longitude string is a number of degrees from -180 to 180
+func isValidLongitude(lonStr string) (float64, bool) {
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil || !(lon >= -180 && lon <= 180) {
 		return 0, false
 	}
-	return rangeNM, true
+	return lon, true
 }
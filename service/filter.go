@@ -0,0 +1,141 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"slices"
+	"strings"
+)
+
+// AirportFilter restricts the airports found around a point. The zero value keeps every airport.
+// Missing data is read as the airport reports it: an unknown country or continent is not excluded,
+// an unknown elevation is 0 ft, and an airport without any runway data is not dropped by MinRunwayFt.
+type AirportFilter struct {
+	// Types keeps the airports of these types
+	Types []string
+
+	// Countries keeps the airports of these ISO country codes, ExcludedCountries drops them
+	Countries         []string
+	ExcludedCountries []string
+
+	// Continents keeps the airports of these continent codes, ExcludedContinents drops them
+	Continents         []string
+	ExcludedContinents []string
+
+	// ScheduledService keeps the airports with a scheduled airline service
+	ScheduledService bool
+
+	// MinElevationFt and MaxElevationFt bound the elevation of the airports
+	MinElevationFt *int
+	MaxElevationFt *int
+
+	// MinRunwayFt keeps the airports having an open runway at least this long, and those without runway data
+	MinRunwayFt int
+}
+
+// clause returns the SQL conditions on the airports table, and their arguments, selecting the airports
+// kept by the filter. The clause is empty when the filter keeps every airport.
+func (f AirportFilter) clause() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	in := func(column string, values []string, not bool) {
+		if len(values) == 0 {
+			return
+		}
+		condition := column + " IN (" + SQLPlaceholders(len(values)) + ")"
+		if not {
+			condition = "(" + column + " IS NULL OR " + column + " NOT IN (" + SQLPlaceholders(len(values)) + "))"
+		}
+		conditions = append(conditions, condition)
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+
+	in("type", f.Types, false)
+	in("iso_country", upper(f.Countries), false)
+	in("iso_country", upper(f.ExcludedCountries), true)
+	in("continent", upper(f.Continents), false)
+	in("continent", upper(f.ExcludedContinents), true)
+
+	if f.ScheduledService {
+		conditions = append(conditions, "scheduled_service = 'yes'")
+	}
+	if f.MinElevationFt != nil {
+		conditions = append(conditions, "COALESCE(elevation_ft, 0) >= ?")
+		args = append(args, *f.MinElevationFt)
+	}
+	if f.MaxElevationFt != nil {
+		conditions = append(conditions, "COALESCE(elevation_ft, 0) <= ?")
+		args = append(args, *f.MaxElevationFt)
+	}
+	if f.MinRunwayFt > 0 {
+		conditions = append(conditions, `(NOT EXISTS (SELECT 1 FROM runways WHERE runways.airport_ref = airports.id)
+			OR EXISTS (SELECT 1 FROM runways
+				WHERE runways.airport_ref = airports.id AND COALESCE(runways.closed, 0) = 0 AND runways.length_ft >= ?))`)
+		args = append(args, f.MinRunwayFt)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// matches tells whether the filter keeps the airport
+func (f AirportFilter) matches(airport Airport) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, airport.Type) {
+		return false
+	}
+	if len(f.Countries) > 0 && !containsFold(f.Countries, airport.IsoCountry) {
+		return false
+	}
+	if containsFold(f.ExcludedCountries, airport.IsoCountry) {
+		return false
+	}
+	if len(f.Continents) > 0 && !containsFold(f.Continents, airport.Continent) {
+		return false
+	}
+	if containsFold(f.ExcludedContinents, airport.Continent) {
+		return false
+	}
+	if f.ScheduledService && airport.ScheduledService != "yes" {
+		return false
+	}
+	if f.MinElevationFt != nil && airport.ElevationFt < *f.MinElevationFt {
+		return false
+	}
+	if f.MaxElevationFt != nil && airport.ElevationFt > *f.MaxElevationFt {
+		return false
+	}
+	if f.MinRunwayFt > 0 && len(airport.Runways) > 0 && longestOpenRunway(airport) < f.MinRunwayFt {
+		return false
+	}
+	return true
+}
+
+// upper returns the upper-cased codes
+func upper(codes []string) []string {
+	result := make([]string, len(codes))
+	for i, code := range codes {
+		result[i] = strings.ToUpper(code)
+	}
+	return result
+}
+
+// containsFold tells whether the codes contain the given one, ignoring case
+func containsFold(codes []string, code string) bool {
+	return slices.ContainsFunc(codes, func(c string) bool {
+		return strings.EqualFold(c, code)
+	})
+}
+
+// longestOpenRunway returns the length of the longest runway of the airport which is not closed
+func longestOpenRunway(airport Airport) int {
+	longest := 0
+	for _, runway := range airport.Runways {
+		if !runway.Closed {
+			longest = max(longest, runway.LengthFt)
+		}
+	}
+	return longest
+}
@@ -0,0 +1,53 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAirportFilter(t *testing.T) {
+	origin := testAirports[0]
+	elevation := func(ft int) *int { return &ft }
+
+	tests := []struct {
+		name   string
+		filter AirportFilter
+		want   []string
+	}{
+		{name: "all", want: []string{"LFXX", "LFPO", "FR-0001", "EGLL", "LSGG"}},
+		{name: "countries", filter: AirportFilter{Countries: []string{"fr", "CH"}}, want: []string{"LFPO", "FR-0001", "LSGG"}},
+		// LFXX has no country, so it is not excluded
+		{name: "excluded countries", filter: AirportFilter{ExcludedCountries: []string{"FR", "gb"}}, want: []string{"LFXX", "LSGG"}},
+		{name: "continents", filter: AirportFilter{Continents: []string{"NA"}}, want: []string{}},
+		{name: "excluded continents", filter: AirportFilter{ExcludedContinents: []string{"EU"}}, want: []string{}},
+		{name: "scheduled service", filter: AirportFilter{ScheduledService: true}, want: []string{"LFPO", "EGLL", "LSGG"}},
+		// FR-0001 has no elevation, read as 0 ft
+		{name: "min elevation", filter: AirportFilter{MinElevationFt: elevation(100)}, want: []string{"LFXX", "LFPO", "LSGG"}},
+		{name: "max elevation", filter: AirportFilter{MaxElevationFt: elevation(200)}, want: []string{"LFXX", "FR-0001", "EGLL"}},
+		{name: "elevation range", filter: AirportFilter{MinElevationFt: elevation(0), MaxElevationFt: elevation(0)}, want: []string{"FR-0001"}},
+		// LFXX and FR-0001 have no runway data, so they are kept, and the runway of LSGG with no closed flag is open
+		{name: "min runway", filter: AirportFilter{MinRunwayFt: 12000}, want: []string{"LFXX", "FR-0001", "EGLL", "LSGG"}},
+		// the closed runway of EGLL does not count
+		{name: "closed runway", filter: AirportFilter{MinRunwayFt: 13000}, want: []string{"LFXX", "FR-0001"}},
+		{
+			name:   "combined",
+			filter: AirportFilter{Types: []string{"large_airport", "medium_airport"}, ExcludedCountries: []string{"GB"}, MinRunwayFt: 12000},
+			want:   []string{"LSGG"},
+		},
+	}
+
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		for _, tt := range tests {
+			airports, err := store.AirportsInRange(&origin, 500, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reachableIdents(airports); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: AirportsInRange() = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
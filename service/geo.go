@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import "math"
+
+const (
+	// Earth's radius in nautical miles
+	earthRadiusNM = 3440.065
+
+	// maxDistanceNM is the longest great circle distance, between antipodes
+	maxDistanceNM = math.Pi * earthRadiusNM
+)
+
+// Distance computes the great circle distance between two points using the haversine formula
+// Returns distance in nautical miles
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	// Convert degrees to radians
+	lat1Rad := lat1 * math.Pi / 180
+	lon1Rad := lon1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	lon2Rad := lon2 * math.Pi / 180
+
+	// Haversine formula
+	dLat := lat2Rad - lat1Rad
+	dLon := lon2Rad - lon1Rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	distance := earthRadiusNM * c
+	return distance
+}
+
+// Bearing computes the initial true bearing, in degrees from 0 to 360, of the great circle
+// from the first point to the second one
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	y := math.Sin(dLon) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
+
+	bearing := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(bearing+360, 360)
+}
+
+// BoundingBox returns the latitude and longitude bounds of every point that may be
+// within rangeNM nautical miles of the given point. The longitude bounds may exceed
+// [-180, 180] when the box crosses the antimeridian.
+func BoundingBox(lat, lon, rangeNM float64) (minLat, maxLat, minLon, maxLon float64) {
+	// Compute bounding box: 1 deg lat ~ 60 NM, 1 deg lon ~ 60*cos(lat) NM
+	latDelta := rangeNM / 60.0
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if cosLat < 0.01 {
+		cosLat = 0.01 // avoid division by zero near poles
+	}
+	lonDelta := rangeNM / (60.0 * cosLat)
+
+	return lat - latDelta, lat + latDelta, lon - lonDelta, lon + lonDelta
+}
+
+// BoundingBoxClause returns a SQL condition on latitude_deg/longitude_deg, and its arguments,
+// selecting every row that may be within rangeNM nautical miles of the given point.
+// Candidates still need to be filtered with Distance.
+func BoundingBoxClause(lat, lon, rangeNM float64) (string, []interface{}) {
+	minLat, maxLat, minLon, maxLon := BoundingBox(lat, lon, rangeNM)
+
+	if minLon < -180 || maxLon > 180 {
+		// Antimeridian crossing: split into two longitude ranges
+		clause := "latitude_deg BETWEEN ? AND ? AND (longitude_deg >= ? OR longitude_deg <= ?)"
+		if minLon < -180 {
+			return clause, []interface{}{minLat, maxLat, minLon + 360, maxLon}
+		}
+		return clause, []interface{}{minLat, maxLat, minLon, maxLon - 360}
+	}
+
+	return "latitude_deg BETWEEN ? AND ? AND longitude_deg BETWEEN ? AND ?", []interface{}{minLat, maxLat, minLon, maxLon}
+}
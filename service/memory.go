@@ -0,0 +1,191 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MemoryStore is an AirportStore holding its airports in memory, e.g. to test code using a store
+// without building a database. The airports are given with their runways, and returned with them where
+// SQLiteStore attaches them. They are searched by substring rather than full-text matching.
+type MemoryStore struct {
+	airports  []Airport
+	countries []Country
+	statuses  []ImportStatus
+}
+
+// NewMemoryStore returns a store of the given airports, countries and import status
+func NewMemoryStore(airports []Airport, countries []Country, statuses []ImportStatus) *MemoryStore {
+	store := &MemoryStore{
+		airports:  append([]Airport(nil), airports...),
+		countries: append([]Country(nil), countries...),
+		statuses:  append([]ImportStatus(nil), statuses...),
+	}
+
+	sort.SliceStable(store.countries, func(i, j int) bool {
+		return store.countries[i].Name < store.countries[j].Name
+	})
+	sort.SliceStable(store.statuses, func(i, j int) bool {
+		return store.statuses[i].TableName < store.statuses[j].TableName
+	})
+
+	return store
+}
+
+// SearchAirports returns the airports whose name, municipality or keywords contain the search text,
+// or one of whose codes is the search text, ranked like SQLiteStore.SearchAirports
+func (s *MemoryStore) SearchAirports(text string, country string) ([]Airport, error) {
+	code := strings.ToUpper(strings.TrimSpace(text))
+	pattern := strings.ToLower(strings.TrimSpace(text))
+
+	var airports []Airport
+	for _, airport := range s.airports {
+		if country != "" && !strings.EqualFold(airport.IsoCountry, country) {
+			continue
+		}
+
+		if hasCode(airport, code) ||
+			strings.Contains(strings.ToLower(airport.Name), pattern) ||
+			strings.Contains(strings.ToLower(airport.Municipality), pattern) ||
+			strings.Contains(strings.ToLower(airport.Keywords), pattern) {
+			airports = append(airports, airport)
+		}
+	}
+
+	sort.SliceStable(airports, func(i, j int) bool {
+		a, b := airports[i], airports[j]
+		if hasCode(a, code) != hasCode(b, code) {
+			return hasCode(a, code)
+		}
+		if TypeRank(a.Type) != TypeRank(b.Type) {
+			return TypeRank(a.Type) < TypeRank(b.Type)
+		}
+		if longestRunway(a) != longestRunway(b) {
+			return longestRunway(a) > longestRunway(b)
+		}
+		return a.Name < b.Name
+	})
+
+	return airports, nil
+}
+
+// AirportByCode returns the airport having the given ICAO code, or ErrNotFound
+func (s *MemoryStore) AirportByCode(code string) (*Airport, error) {
+	for _, airport := range s.airports {
+		if airport.IcaoCode != "" && strings.EqualFold(airport.IcaoCode, code) {
+			return &airport, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ResolveCode returns the airport having the given code of any kind
+func (s *MemoryStore) ResolveCode(code string) (*CodeMatch, error) {
+	return resolveCode(code, s.airports)
+}
+
+// AirportsInRange returns the airports within rangeNM nautical miles of the origin airport kept by the filter,
+// closest first
+func (s *MemoryStore) AirportsInRange(origin *Airport, rangeNM float64, filter AirportFilter) ([]ReachableAirport, error) {
+	return excludeOrigin(s.airportsAround(origin.LatitudeDeg, origin.LongitudeDeg, rangeNM, filter)), nil
+}
+
+// NearestAirports returns the n airports closest to the given point kept by the filter, closest first
+func (s *MemoryStore) NearestAirports(lat, lon float64, n int, filter AirportFilter) ([]NearestAirport, error) {
+	return nearestAirports(lat, lon, n, func(rangeNM float64) ([]ReachableAirport, error) {
+		return s.airportsAround(lat, lon, rangeNM, filter), nil
+	})
+}
+
+// airportsAround returns the airports within rangeNM nautical miles of the given point kept by the filter,
+// closest first
+func (s *MemoryStore) airportsAround(lat, lon, rangeNM float64, filter AirportFilter) []ReachableAirport {
+	var results []ReachableAirport
+	for _, airport := range s.airports {
+		if !filter.matches(airport) {
+			continue
+		}
+
+		dist := Distance(lat, lon, airport.LatitudeDeg, airport.LongitudeDeg)
+		if dist <= rangeNM {
+			results = append(results, ReachableAirport{
+				Airport:    withoutRunways(airport),
+				DistanceNM: dist,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].DistanceNM < results[j].DistanceNM
+	})
+
+	return results
+}
+
+// AirportsInCountry returns the airports of the country, sorted by name
+func (s *MemoryStore) AirportsInCountry(country string, types []string) ([]Airport, error) {
+	var airports []Airport
+	for _, airport := range s.airports {
+		if !strings.EqualFold(airport.IsoCountry, country) {
+			continue
+		}
+		if len(types) > 0 && !slices.Contains(types, airport.Type) {
+			continue
+		}
+		airports = append(airports, withoutRunways(airport))
+	}
+
+	sort.SliceStable(airports, func(i, j int) bool {
+		return airports[i].Name < airports[j].Name
+	})
+
+	return airports, nil
+}
+
+// Countries returns every country, sorted by name
+func (s *MemoryStore) Countries() ([]Country, error) {
+	return append([]Country(nil), s.countries...), nil
+}
+
+// CountryByCode returns the country having the given ISO code, or ErrNotFound
+func (s *MemoryStore) CountryByCode(code string) (*Country, error) {
+	for _, country := range s.countries {
+		if strings.EqualFold(country.Code, code) {
+			return &country, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ImportStatus returns the import status the store was created with, sorted by table name
+func (s *MemoryStore) ImportStatus() ([]ImportStatus, error) {
+	return append([]ImportStatus(nil), s.statuses...), nil
+}
+
+// hasCode tells whether one of the codes of the airport is the given upper-cased code
+func hasCode(airport Airport, code string) bool {
+	for _, c := range []string{airport.IcaoCode, airport.IataCode, airport.Ident, airport.GpsCode, airport.LocalCode} {
+		if c != "" && strings.ToUpper(c) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutRunways returns the airport without its runways, as SQLiteStore returns the airports of a list
+func withoutRunways(airport Airport) Airport {
+	airport.Runways = nil
+	return airport
+}
+
+func longestRunway(airport Airport) int {
+	longest := 0
+	for _, runway := range airport.Runways {
+		longest = max(longest, runway.LengthFt)
+	}
+	return longest
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CodeKind is the kind of code an airport was resolved by
+type CodeKind string
+
+const (
+	CodeICAO  CodeKind = "icao"
+	CodeIATA  CodeKind = "iata"
+	CodeIdent CodeKind = "ident"
+	CodeGPS   CodeKind = "gps"
+	CodeLocal CodeKind = "local"
+)
+
+// codeKinds lists the kinds of code from the most to the least specific.
+// A code resolves to the airports matching its most specific kind, so that e.g. an ICAO code
+// wins over the same local code of an airport on the other side of the world.
+var codeKinds = []CodeKind{CodeICAO, CodeIATA, CodeIdent, CodeGPS, CodeLocal}
+
+// CodeMatch is an airport found by one of its codes
+type CodeMatch struct {
+	Airport Airport  `json:"airport"`
+	Kind    CodeKind `json:"match"`
+}
+
+// AmbiguousCodeError is returned when a code resolves to several airports
+type AmbiguousCodeError struct {
+	Code       string
+	Candidates []CodeMatch
+}
+
+func (e *AmbiguousCodeError) Error() string {
+	return fmt.Sprintf("code %s matches %d airports", e.Code, len(e.Candidates))
+}
+
+// airportCode returns the code of the given kind of the airport
+func airportCode(airport Airport, kind CodeKind) string {
+	switch kind {
+	case CodeICAO:
+		return airport.IcaoCode
+	case CodeIATA:
+		return airport.IataCode
+	case CodeIdent:
+		return airport.Ident
+	case CodeGPS:
+		return airport.GpsCode
+	case CodeLocal:
+		return airport.LocalCode
+	}
+	return ""
+}
+
+// resolveCode picks, among the given airports, the ones matching the code by its most specific kind.
+// Closed airports are only considered when no open airport matches by that kind. It returns ErrNotFound
+// when none matches, and an AmbiguousCodeError listing the candidates when several airports match.
+func resolveCode(code string, airports []Airport) (*CodeMatch, error) {
+	if code == "" {
+		return nil, ErrNotFound
+	}
+
+	for _, kind := range codeKinds {
+		var candidates []CodeMatch
+		for _, airport := range airports {
+			if strings.EqualFold(airportCode(airport, kind), code) {
+				candidates = append(candidates, CodeMatch{Airport: airport, Kind: kind})
+			}
+		}
+
+		if len(candidates) == 0 {
+			continue
+		}
+
+		// A code reused by an open airport refers to it rather than to the closed ones
+		open := slices.DeleteFunc(slices.Clone(candidates), func(c CodeMatch) bool {
+			return c.Airport.Type == "closed"
+		})
+		if len(open) > 0 {
+			candidates = open
+		}
+		if len(candidates) == 1 {
+			return &candidates[0], nil
+		}
+
+		// Larger airports first, as they are the most likely to be meant
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return TypeRank(candidates[i].Airport.Type) < TypeRank(candidates[j].Airport.Type)
+		})
+		return nil, &AmbiguousCodeError{Code: strings.ToUpper(code), Candidates: candidates}
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveCodeClosedAirports(t *testing.T) {
+	airports := []Airport{
+		{ID: 1, Ident: "AAAA", Type: "closed", IataCode: "ONE"},
+		{ID: 2, Ident: "BBBB", Type: "small_airport", IataCode: "ONE"},
+		{ID: 3, Ident: "CCCC", Type: "closed", IataCode: "TWO"},
+		{ID: 4, Ident: "DDDD", Type: "closed", IataCode: "TWO"},
+		{ID: 5, Ident: "EEEE", Type: "closed", IataCode: "SIX"},
+		{ID: 6, Ident: "FFFF", Type: "heliport", IataCode: "SIX"},
+		{ID: 7, Ident: "GGGG", Type: "large_airport", IataCode: "SIX"},
+		{ID: 8, Ident: "HHHH", Type: "closed", IataCode: "OLD"},
+	}
+
+	tests := []struct {
+		code       string
+		want       string
+		candidates []string
+	}{
+		// the open airport wins over the closed one
+		{code: "ONE", want: "BBBB"},
+		// only closed airports: all of them are candidates
+		{code: "TWO", candidates: []string{"CCCC", "DDDD"}},
+		// several open airports: the closed one is not a candidate
+		{code: "SIX", candidates: []string{"GGGG", "FFFF"}},
+		// a closed airport alone is still found
+		{code: "OLD", want: "HHHH"},
+	}
+
+	for _, tt := range tests {
+		match, err := resolveCode(tt.code, airports)
+
+		var ambiguous *AmbiguousCodeError
+		if errors.As(err, &ambiguous) {
+			var idents []string
+			for _, c := range ambiguous.Candidates {
+				idents = append(idents, c.Airport.Ident)
+			}
+			if !reflect.DeepEqual(idents, tt.candidates) {
+				t.Errorf("resolveCode(%q) candidates = %v, want %v", tt.code, idents, tt.candidates)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("resolveCode(%q) error = %v", tt.code, err)
+		}
+		if match.Airport.Ident != tt.want || tt.candidates != nil {
+			t.Errorf("resolveCode(%q) = %s, want %s (candidates %v)", tt.code, match.Airport.Ident, tt.want, tt.candidates)
+		}
+	}
+}
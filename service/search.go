@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+
+	askdb "ask/db"
+)
+
+// airportSearchOrder ranks the search results: exact code matches first, then larger airports
+// (by type, then by longest runway), then the best text matches.
+// The first parameter is the upper-cased search text.
+const airportSearchOrder = ` ORDER BY
+	CASE WHEN ? IN (icao_code, iata_code, ident, gps_code, local_code) THEN 0 ELSE 1 END,
+	CASE type
+		WHEN 'large_airport' THEN 0
+		WHEN 'medium_airport' THEN 1
+		WHEN 'small_airport' THEN 2
+		WHEN 'seaplane_base' THEN 3
+		WHEN 'heliport' THEN 4
+		ELSE 5
+	END,
+	(SELECT MAX(length_ft) FROM runways WHERE runways.airport_ref = airports.id) DESC`
+
+// SearchAirports returns the airports matching the search text in their name, municipality,
+// keywords or codes, ranked by airportSearchOrder, with their runways. It uses the full-text index
+// built at import, and falls back to LIKE matching when the index or FTS5 support is missing,
+// or when the text has no word to look up in the index, e.g. when it is only punctuation.
+func (s *SQLiteStore) SearchAirports(text string, country string) ([]Airport, error) {
+	var airports []Airport
+	var err error
+	if ftsQuery(text) == "" {
+		airports, err = searchAirportsLike(s.db, text, country)
+	} else {
+		airports, err = searchAirportsFTS(s.db, text, country)
+		if err != nil && searchIndexMissing(err) {
+			airports, err = searchAirportsLike(s.db, text, country)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := attachRunways(s.db, airports); err != nil {
+		return nil, err
+	}
+
+	return airports, nil
+}
+
+func searchAirportsFTS(db *sql.DB, text string, country string) ([]Airport, error) {
+	var query strings.Builder
+	var args []interface{}
+
+	query.WriteString(airportSelectColumns + ` JOIN (
+		SELECT rowid AS match_id, bm25(` + askdb.SearchIndexTable + `) AS match_score
+		FROM ` + askdb.SearchIndexTable + ` WHERE ` + askdb.SearchIndexTable + ` MATCH ?
+	) AS matches ON matches.match_id = airports.id`)
+	args = append(args, ftsQuery(text))
+
+	if country != "" {
+		query.WriteString(" WHERE LOWER(iso_country) = LOWER(?)")
+		args = append(args, country)
+	}
+
+	query.WriteString(airportSearchOrder + ", match_score")
+	args = append(args, strings.ToUpper(strings.TrimSpace(text)))
+
+	return queryAirports(db, query.String(), args...)
+}
+
+func searchAirportsLike(db *sql.DB, text string, country string) ([]Airport, error) {
+	var query strings.Builder
+	var args []interface{}
+
+	pattern := "%" + strings.TrimSpace(text) + "%"
+	query.WriteString(airportSelectColumns + ` WHERE (LOWER(name) LIKE LOWER(?) OR LOWER(municipality) LIKE LOWER(?)
+		OR LOWER(keywords) LIKE LOWER(?) OR UPPER(?) IN (ident, icao_code, iata_code))`)
+	args = append(args, pattern, pattern, pattern, strings.TrimSpace(text))
+
+	if country != "" {
+		query.WriteString(" AND LOWER(iso_country) = LOWER(?)")
+		args = append(args, country)
+	}
+
+	query.WriteString(airportSearchOrder + ", name")
+	args = append(args, strings.ToUpper(strings.TrimSpace(text)))
+
+	return queryAirports(db, query.String(), args...)
+}
+
+// searchIndexMissing tells whether the full-text search failed because the database has no search index,
+// e.g. when it was built before the index existed, or because SQLite was built without FTS5
+func searchIndexMissing(err error) bool {
+	return strings.Contains(err.Error(), "no such table: "+askdb.SearchIndexTable) ||
+		strings.Contains(err.Error(), "no such module: fts5")
+}
+
+// ftsQuery turns the search text into an FTS5 query matching every word as a prefix,
+// so that "heath" matches "Heathrow"
+func ftsQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// queryAirports runs a query selecting airportSelectColumns and scans every airport
+func queryAirports(db *sql.DB, query string, args ...interface{}) ([]Airport, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var airports []Airport
+	for rows.Next() {
+		airport, err := scanAirport(rows)
+		if err != nil {
+			return nil, err
+		}
+		airports = append(airports, airport)
+	}
+
+	return airports, rows.Err()
+}
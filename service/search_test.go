@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	askdb "ask/db"
+)
+
+// buildTestSearchIndex builds the search index of the store as the import does,
+// leaving the store without index when SQLite was built without FTS5
+func buildTestSearchIndex(t *testing.T, store *SQLiteStore) {
+	t.Helper()
+
+	_, err := store.db.Exec(`CREATE VIRTUAL TABLE ` + askdb.SearchIndexTable + ` USING fts5(
+		name, municipality, keywords, ident, icao_code, iata_code,
+		content='airports', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	)`)
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Log("SQLite built without FTS5 (sqlite_fts5 build tag), testing the LIKE search only")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.db.Exec(`INSERT INTO ` + askdb.SearchIndexTable + `(` + askdb.SearchIndexTable + `) VALUES ('rebuild')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchAirports(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	buildTestSearchIndex(t, store)
+
+	tests := []struct {
+		text    string
+		country string
+		want    []string
+	}{
+		{text: "heathrow", want: []string{"EGLL"}},
+		{text: "Charles de Gaulle", want: []string{"LFPG"}},
+		{text: "orly", country: "gb", want: []string{}},
+		{text: "LSGG", want: []string{"LSGG"}},
+		// Only punctuation: nothing to look up in the index, the LIKE search answers
+		{text: ".", want: []string{}},
+		{text: "-", want: []string{}},
+		{text: "'", want: []string{}},
+		{text: " . ", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		airports, err := store.SearchAirports(tt.text, tt.country)
+		if err != nil {
+			t.Fatalf("SearchAirports(%q) error = %v", tt.text, err)
+		}
+
+		idents := []string{}
+		for _, a := range airports {
+			idents = append(idents, a.Ident)
+		}
+		if !reflect.DeepEqual(idents, tt.want) {
+			t.Errorf("SearchAirports(%q, %q) = %v, want %v", tt.text, tt.country, idents, tt.want)
+		}
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "heath", want: `"heath"*`},
+		{text: "St. John's", want: `"St"* "John"* "s"*`},
+		{text: "Saint-Étienne", want: `"Saint"* "Étienne"*`},
+		{text: ".", want: ""},
+		{text: "- ' .", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ftsQuery(tt.text); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
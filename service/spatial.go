@@ -0,0 +1,172 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+
+	askdb "ask/db"
+)
+
+// spatialIndexMaxRangeNM is the range above which the candidates are read by scanning the airports table
+// rather than through the spatial index: past that range, they are a large share of the airports and
+// fetching them one by one through the index gets slower than reading the whole table (see BenchmarkAirportsInRange)
+const spatialIndexMaxRangeNM = 200.0
+
+// nearestInitialRangeNM is the range, in nautical miles, of the first search for the nearest airports of a point
+const nearestInitialRangeNM = 50.0
+
+// spatialIndexQuery is the R*Tree counterpart of BoundingBoxClause: it returns a query, and its
+// arguments, selecting through the airports_rtree index the ids of the airports that may be
+// within rangeNM nautical miles of the given point
+func spatialIndexQuery(lat, lon, rangeNM float64) (string, []interface{}) {
+	minLat, maxLat, minLon, maxLon := BoundingBox(lat, lon, rangeNM)
+
+	box := "SELECT id FROM " + askdb.SpatialIndexTable + " WHERE max_lat >= ? AND min_lat <= ? AND max_lon >= ? AND min_lon <= ?"
+
+	if minLon < -180 || maxLon > 180 {
+		// Antimeridian crossing: one box on each side, as the R*Tree cannot use an OR
+		if minLon < -180 {
+			return box + " UNION ALL " + box,
+				[]interface{}{minLat, maxLat, minLon + 360, 180.0, minLat, maxLat, -180.0, maxLon}
+		}
+		return box + " UNION ALL " + box,
+			[]interface{}{minLat, maxLat, minLon, 180.0, minLat, maxLat, -180.0, maxLon - 360}
+	}
+
+	return box, []interface{}{minLat, maxLat, minLon, maxLon}
+}
+
+// spatialIndexMissing tells whether a query failed because the database has no spatial index,
+// e.g. when it was built before the index existed
+func spatialIndexMissing(err error) bool {
+	return strings.Contains(err.Error(), "no such table: "+askdb.SpatialIndexTable)
+}
+
+// AirportsInRange finds all airports within rangeNM nautical miles of the origin airport
+// kept by the filter.
+// Candidates are selected through the spatial index built at import, or by scanning the
+// airports table when the database has no such index or the range is above spatialIndexMaxRangeNM.
+func (s *SQLiteStore) AirportsInRange(origin *Airport, rangeNM float64, filter AirportFilter) ([]ReachableAirport, error) {
+	airports, err := s.airportsAround(origin.LatitudeDeg, origin.LongitudeDeg, rangeNM, filter)
+	if err != nil {
+		return nil, err
+	}
+	return excludeOrigin(airports), nil
+}
+
+// NearestAirports returns the n airports closest to the given point, closest first, with their distance
+// and bearing from it, among the airports kept by the filter.
+func (s *SQLiteStore) NearestAirports(lat, lon float64, n int, filter AirportFilter) ([]NearestAirport, error) {
+	return nearestAirports(lat, lon, n, func(rangeNM float64) ([]ReachableAirport, error) {
+		return s.airportsAround(lat, lon, rangeNM, filter)
+	})
+}
+
+// airportsAround returns the airports within rangeNM nautical miles of the given point kept by the filter,
+// closest first, through the spatial index up to spatialIndexMaxRangeNM
+func (s *SQLiteStore) airportsAround(lat, lon, rangeNM float64, filter AirportFilter) ([]ReachableAirport, error) {
+	if rangeNM <= spatialIndexMaxRangeNM {
+		indexQuery, args := spatialIndexQuery(lat, lon, rangeNM)
+		airports, err := queryAirportsInRange(s.db, lat, lon, rangeNM, filter, "id IN ("+indexQuery+")", args)
+		if err == nil || !spatialIndexMissing(err) {
+			return airports, err
+		}
+	}
+
+	clause, args := BoundingBoxClause(lat, lon, rangeNM)
+	return queryAirportsInRange(s.db, lat, lon, rangeNM, filter, clause, args)
+}
+
+// queryAirportsInRange selects the candidate airports matching the given clause and the filter,
+// and keeps the ones within rangeNM nautical miles of the point, sorted by distance.
+// The distances are not rounded.
+func queryAirportsInRange(db *sql.DB, lat, lon, rangeNM float64, filter AirportFilter, clause string, args []interface{}) ([]ReachableAirport, error) {
+	query := airportSelectColumns + " WHERE " + clause
+
+	if filterClause, filterArgs := filter.clause(); filterClause != "" {
+		query += " AND " + filterClause
+		args = append(args, filterArgs...)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ReachableAirport
+	for rows.Next() {
+		airport, err := scanAirport(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		dist := Distance(lat, lon, airport.LatitudeDeg, airport.LongitudeDeg)
+		if dist <= rangeNM {
+			results = append(results, ReachableAirport{
+				Airport:    airport,
+				DistanceNM: dist,
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceNM < results[j].DistanceNM
+	})
+
+	return results, nil
+}
+
+// excludeOrigin drops the origin airport, at a null distance, from the airports found around it,
+// and rounds the distances of the others to 1 decimal place
+func excludeOrigin(airports []ReachableAirport) []ReachableAirport {
+	var results []ReachableAirport
+	for _, airport := range airports {
+		if airport.DistanceNM > 0.01 {
+			airport.DistanceNM = math.Round(airport.DistanceNM*10) / 10
+			results = append(results, airport)
+		}
+	}
+	return results
+}
+
+// nearestAirports looks for the n airports closest to the given point with the within function,
+// returning the airports in a range around the point closest first. The range starts at
+// nearestInitialRangeNM and doubles until it holds n airports or covers the whole Earth.
+func nearestAirports(lat, lon float64, n int, within func(rangeNM float64) ([]ReachableAirport, error)) ([]NearestAirport, error) {
+	var airports []ReachableAirport
+	for rangeNM := nearestInitialRangeNM; ; rangeNM *= 2 {
+		rangeNM = min(rangeNM, maxDistanceNM)
+
+		var err error
+		airports, err = within(rangeNM)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every airport out of range is farther than the ones found
+		if len(airports) >= n || rangeNM == maxDistanceNM {
+			break
+		}
+	}
+
+	results := make([]NearestAirport, 0, min(n, len(airports)))
+	for _, airport := range airports[:min(n, len(airports))] {
+		results = append(results, NearestAirport{
+			Airport:    airport.Airport,
+			DistanceNM: math.Round(airport.DistanceNM*10) / 10,
+			BearingDeg: math.Round(Bearing(lat, lon, airport.Airport.LatitudeDeg, airport.Airport.LongitudeDeg)*10) / 10,
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	askdb "ask/db"
+)
+
+// benchmarkAirportCount is about the number of airports of the ourairports dataset
+const benchmarkAirportCount = 80000
+
+// openBenchmarkStore builds a database of random airports, half of them spread over the world
+// and half of them around Europe to get dense areas like the real data, with its spatial index
+func openBenchmarkStore(b *testing.B) *SQLiteStore {
+	b.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(b.TempDir(), "ask.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	if err := askdb.Migrate(db); err != nil {
+		b.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(1))
+	for i := 1; i <= benchmarkAirportCount; i++ {
+		lat, lon := random.Float64()*140-60, random.Float64()*360-180
+		if i%2 == 0 {
+			lat, lon = 48+random.NormFloat64()*8, 10+random.NormFloat64()*15
+		}
+		_, err := tx.Exec(`INSERT INTO airports (id, ident, type, name, latitude_deg, longitude_deg)
+			VALUES (?, ?, 'small_airport', ?, ?, ?)`, i, fmt.Sprintf("BM%05d", i), fmt.Sprintf("Airport %d", i), lat, lon)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO import_status (table_name, last_import_date, record_count) VALUES ('airports', '', ?)`,
+		benchmarkAirportCount)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// As built at import by db.buildSpatialIndex
+	_, err = db.Exec(`CREATE VIRTUAL TABLE ` + askdb.SpatialIndexTable + ` USING rtree(id, min_lat, max_lat, min_lon, max_lon)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO ` + askdb.SpatialIndexTable + ` (id, min_lat, max_lat, min_lon, max_lon)
+		SELECT id, latitude_deg, latitude_deg, longitude_deg, longitude_deg FROM airports`)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return NewSQLiteStore(db)
+}
+
+// BenchmarkAirportsInRange compares, from Paris, the selection of the candidates through the R*Tree index
+// with the bounding box scan of the airports table, and with AirportsInRange picking one of them
+func BenchmarkAirportsInRange(b *testing.B) {
+	store := openBenchmarkStore(b)
+	origin := &Airport{LatitudeDeg: 49.0097, LongitudeDeg: 2.5479}
+
+	for _, rangeNM := range []float64{50, 200, 500, 1000} {
+		b.Run(fmt.Sprintf("rtree/%gNM", rangeNM), func(b *testing.B) {
+			indexQuery, args := spatialIndexQuery(origin.LatitudeDeg, origin.LongitudeDeg, rangeNM)
+			for i := 0; i < b.N; i++ {
+				_, err := queryAirportsInRange(store.db, origin.LatitudeDeg, origin.LongitudeDeg, rangeNM, AirportFilter{},
+					"id IN ("+indexQuery+")", args)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("bbox/%gNM", rangeNM), func(b *testing.B) {
+			clause, args := BoundingBoxClause(origin.LatitudeDeg, origin.LongitudeDeg, rangeNM)
+			for i := 0; i < b.N; i++ {
+				_, err := queryAirportsInRange(store.db, origin.LatitudeDeg, origin.LongitudeDeg, rangeNM, AirportFilter{},
+					clause, args)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("auto/%gNM", rangeNM), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := store.AirportsInRange(origin, rangeNM, AirportFilter{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
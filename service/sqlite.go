@@ -0,0 +1,495 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	askdb "ask/db"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStore is the AirportStore of a database built by `ask init`
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore returns the store of an open database
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+// OpenSQLiteStore opens the database at dbPath for queries and returns its store, which must be closed
+func OpenSQLiteStore(dbPath string) (*SQLiteStore, error) {
+	db, err := OpenDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLiteStore(db), nil
+}
+
+// OpenDatabase opens the database at dbPath for queries. It leaves the schema as is,
+// and refuses a database migrated by a newer binary.
+func OpenDatabase(dbPath string) (*sql.DB, error) {
+	return openDatabase(dbPath, askdb.CheckSchemaVersion)
+}
+
+// MigrateDatabase opens the database at dbPath and migrates it to the latest schema,
+// refusing a database migrated by a newer binary
+func MigrateDatabase(dbPath string) (*sql.DB, error) {
+	return openDatabase(dbPath, askdb.Migrate)
+}
+
+// openDatabase opens the database at dbPath and prepares its schema with the given function
+func openDatabase(dbPath string, prepare func(db *sql.DB) error) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := prepare(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// DB returns the database of the store
+func (s *SQLiteStore) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database of the store
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// airportSelectColumns selects every airport column, plus the name of its region, in the order expected by scanAirport
+const airportSelectColumns = `SELECT id, ident, type, name, latitude_deg, longitude_deg,
+	elevation_ft, continent, iso_country, iso_region, municipality, scheduled_service,
+	icao_code, iata_code, gps_code, local_code, home_link, wikipedia_link, keywords, source, timezone,
+	(SELECT regions.name FROM regions WHERE regions.code = airports.iso_region) as region_name
+	FROM airports`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAirport scans a single row selected with airportSelectColumns
+func scanAirport(row rowScanner) (Airport, error) {
+	var (
+		id               sql.NullInt64
+		ident            sql.NullString
+		airportType      sql.NullString
+		name             sql.NullString
+		latitudeDeg      sql.NullFloat64
+		longitudeDeg     sql.NullFloat64
+		elevationFt      sql.NullInt64
+		continent        sql.NullString
+		isoCountry       sql.NullString
+		isoRegion        sql.NullString
+		municipality     sql.NullString
+		scheduledService sql.NullString
+		icaoCode         sql.NullString
+		iataCode         sql.NullString
+		gpsCode          sql.NullString
+		localCode        sql.NullString
+		homeLink         sql.NullString
+		wikipediaLink    sql.NullString
+		keywords         sql.NullString
+		source           sql.NullString
+		timezone         sql.NullString
+		regionName       sql.NullString
+	)
+
+	err := row.Scan(
+		&id, &ident, &airportType, &name, &latitudeDeg, &longitudeDeg,
+		&elevationFt, &continent, &isoCountry, &isoRegion, &municipality,
+		&scheduledService, &icaoCode, &iataCode, &gpsCode, &localCode,
+		&homeLink, &wikipediaLink, &keywords, &source, &timezone, &regionName,
+	)
+	if err != nil {
+		return Airport{}, err
+	}
+
+	return Airport{
+		ID:               int(id.Int64),
+		Ident:            ident.String,
+		Type:             airportType.String,
+		Name:             name.String,
+		LatitudeDeg:      latitudeDeg.Float64,
+		LongitudeDeg:     longitudeDeg.Float64,
+		ElevationFt:      int(elevationFt.Int64),
+		Continent:        continent.String,
+		IsoCountry:       isoCountry.String,
+		IsoRegion:        isoRegion.String,
+		RegionName:       regionName.String,
+		Municipality:     municipality.String,
+		ScheduledService: scheduledService.String,
+		IcaoCode:         icaoCode.String,
+		IataCode:         iataCode.String,
+		GpsCode:          gpsCode.String,
+		LocalCode:        localCode.String,
+		HomeLink:         homeLink.String,
+		WikipediaLink:    wikipediaLink.String,
+		Keywords:         keywords.String,
+		Source:           source.String,
+		Timezone:         timezone.String,
+	}, nil
+}
+
+// AirportByCode returns the airport having the given ICAO code, with its runways
+func (s *SQLiteStore) AirportByCode(code string) (*Airport, error) {
+	query := airportSelectColumns + " WHERE UPPER(icao_code) = UPPER(?)"
+
+	airport, err := scanAirport(s.db.QueryRow(query, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	airports := []Airport{airport}
+	if err := attachRunways(s.db, airports); err != nil {
+		return nil, err
+	}
+
+	return &airports[0], nil
+}
+
+// ResolveCode returns the airport having the given code of any kind, with its runways
+func (s *SQLiteStore) ResolveCode(code string) (*CodeMatch, error) {
+	query := airportSelectColumns + ` WHERE icao_code = ?1 COLLATE NOCASE OR iata_code = ?1 COLLATE NOCASE
+		OR ident = ?1 COLLATE NOCASE OR gps_code = ?1 COLLATE NOCASE OR local_code = ?1 COLLATE NOCASE`
+
+	airports, err := queryAirports(s.db, query, code)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := resolveCode(code, airports)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []Airport{match.Airport}
+	if err := attachRunways(s.db, matched); err != nil {
+		return nil, err
+	}
+	match.Airport = matched[0]
+
+	return match, nil
+}
+
+// AirportsInCountry returns the airports of the country, sorted by name
+func (s *SQLiteStore) AirportsInCountry(country string, types []string) ([]Airport, error) {
+	query := airportSelectColumns + " WHERE iso_country = ? COLLATE NOCASE"
+	args := []interface{}{country}
+
+	if len(types) > 0 {
+		query += " AND type IN (" + SQLPlaceholders(len(types)) + ")"
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+
+	return queryAirports(s.db, query+" ORDER BY name", args...)
+}
+
+// countrySelectColumns selects every country column, in the order expected by scanCountry
+const countrySelectColumns = "SELECT id, code, name, continent, wikipedia_link, keywords FROM countries"
+
+// scanCountry scans a single row selected with countrySelectColumns
+func scanCountry(row rowScanner) (Country, error) {
+	var (
+		id            sql.NullInt64
+		code          sql.NullString
+		name          sql.NullString
+		continent     sql.NullString
+		wikipediaLink sql.NullString
+		keywords      sql.NullString
+	)
+
+	if err := row.Scan(&id, &code, &name, &continent, &wikipediaLink, &keywords); err != nil {
+		return Country{}, err
+	}
+
+	return Country{
+		ID:            int(id.Int64),
+		Code:          code.String,
+		Name:          name.String,
+		Continent:     continent.String,
+		WikipediaLink: wikipediaLink.String,
+		Keywords:      keywords.String,
+	}, nil
+}
+
+// Countries returns every country, sorted by name
+func (s *SQLiteStore) Countries() ([]Country, error) {
+	rows, err := s.db.Query(countrySelectColumns + " ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var countries []Country
+	for rows.Next() {
+		country, err := scanCountry(rows)
+		if err != nil {
+			return nil, err
+		}
+		countries = append(countries, country)
+	}
+
+	return countries, rows.Err()
+}
+
+// CountryByCode returns the country having the given ISO code
+func (s *SQLiteStore) CountryByCode(code string) (*Country, error) {
+	country, err := scanCountry(s.db.QueryRow(countrySelectColumns+" WHERE code = ? COLLATE NOCASE", code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &country, nil
+}
+
+// ImportStatus returns the last import of every table, sorted by table name
+func (s *SQLiteStore) ImportStatus() ([]ImportStatus, error) {
+	query := `SELECT table_name, last_import_date, COALESCE(git_commit_hash, ''), COALESCE(git_commit_date, ''), record_count,
+			  COALESCE(content_checksum, ''), COALESCE(source_url, ''), COALESCE(pinned_ref, '')
+			  FROM import_status
+			  ORDER BY table_name`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []ImportStatus
+	for rows.Next() {
+		var status ImportStatus
+		err := rows.Scan(&status.TableName, &status.LastImportDate, &status.GitCommitHash, &status.GitCommitDate, &status.RecordCount, &status.ContentChecksum, &status.SourceURL, &status.PinnedRef)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// SQLPlaceholders returns n comma-separated SQL placeholders, e.g. "?,?,?"
+func SQLPlaceholders(n int) string {
+	placeholders := strings.Repeat("?,", n)
+	return placeholders[:len(placeholders)-1] // trim trailing comma
+}
+
+const runwaySelectColumns = `SELECT id, airport_ref, airport_ident,
+	length_ft, width_ft, surface, lighted, closed,
+	le_ident, le_latitude_deg, le_longitude_deg, le_elevation_ft, le_heading_degT, le_displaced_threshold_ft,
+	he_ident, he_latitude_deg, he_longitude_deg, he_elevation_ft, he_heading_degT, he_displaced_threshold_ft
+	FROM runways`
+
+// airportBatchSize is the number of airports whose related rows are loaded by a single query,
+// well below the maximum number of SQL variables of SQLite
+const airportBatchSize = 500
+
+// forEachAirportBatch calls load on consecutive batches of at most airportBatchSize airports,
+// stopping at the first error
+func forEachAirportBatch(airports []Airport, load func(batch []Airport) error) error {
+	for start := 0; start < len(airports); start += airportBatchSize {
+		if err := load(airports[start:min(start+airportBatchSize, len(airports))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachRunways loads the runways of the given airports, by batches of airportBatchSize airports,
+// and sets them on each airport, longest runway first.
+func attachRunways(db *sql.DB, airports []Airport) error {
+	return forEachAirportBatch(airports, func(batch []Airport) error {
+		return attachRunwaysBatch(db, batch)
+	})
+}
+
+// attachRunwaysBatch loads the runways of the given airports in a single query
+func attachRunwaysBatch(db *sql.DB, airports []Airport) error {
+	args := make([]interface{}, len(airports))
+	for i, a := range airports {
+		args[i] = a.ID
+	}
+
+	query := runwaySelectColumns + " WHERE airport_ref IN (" + SQLPlaceholders(len(airports)) + ") ORDER BY airport_ref, length_ft DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	runwaysByAirport := make(map[int][]Runway)
+	for rows.Next() {
+		runway, err := scanRunway(rows)
+		if err != nil {
+			return err
+		}
+		runwaysByAirport[runway.AirportRef] = append(runwaysByAirport[runway.AirportRef], runway)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range airports {
+		airports[i].Runways = runwaysByAirport[airports[i].ID]
+	}
+
+	return nil
+}
+
+// scanRunway scans a single row selected with runwaySelectColumns
+func scanRunway(rows *sql.Rows) (Runway, error) {
+	var (
+		id                     sql.NullInt64
+		airportRef             sql.NullInt64
+		airportIdent           sql.NullString
+		lengthFt               sql.NullInt64
+		widthFt                sql.NullInt64
+		surface                sql.NullString
+		lighted                sql.NullInt64
+		closed                 sql.NullInt64
+		leIdent                sql.NullString
+		leLatitudeDeg          sql.NullFloat64
+		leLongitudeDeg         sql.NullFloat64
+		leElevationFt          sql.NullInt64
+		leHeadingDegT          sql.NullFloat64
+		leDisplacedThresholdFt sql.NullInt64
+		heIdent                sql.NullString
+		heLatitudeDeg          sql.NullFloat64
+		heLongitudeDeg         sql.NullFloat64
+		heElevationFt          sql.NullInt64
+		heHeadingDegT          sql.NullFloat64
+		heDisplacedThresholdFt sql.NullInt64
+	)
+
+	err := rows.Scan(
+		&id, &airportRef, &airportIdent, &lengthFt, &widthFt, &surface,
+		&lighted, &closed,
+		&leIdent, &leLatitudeDeg, &leLongitudeDeg, &leElevationFt, &leHeadingDegT, &leDisplacedThresholdFt,
+		&heIdent, &heLatitudeDeg, &heLongitudeDeg, &heElevationFt, &heHeadingDegT, &heDisplacedThresholdFt,
+	)
+	if err != nil {
+		return Runway{}, err
+	}
+
+	return Runway{
+		ID:           int(id.Int64),
+		AirportRef:   int(airportRef.Int64),
+		AirportIdent: airportIdent.String,
+		LengthFt:     int(lengthFt.Int64),
+		WidthFt:      int(widthFt.Int64),
+		Surface:      surface.String,
+		Lighted:      lighted.Int64 == 1,
+		Closed:       closed.Int64 == 1,
+		LowEnd: RunwayEnd{
+			Ident:                leIdent.String,
+			LatitudeDeg:          leLatitudeDeg.Float64,
+			LongitudeDeg:         leLongitudeDeg.Float64,
+			ElevationFt:          int(leElevationFt.Int64),
+			HeadingDegT:          leHeadingDegT.Float64,
+			DisplacedThresholdFt: int(leDisplacedThresholdFt.Int64),
+		},
+		HighEnd: RunwayEnd{
+			Ident:                heIdent.String,
+			LatitudeDeg:          heLatitudeDeg.Float64,
+			LongitudeDeg:         heLongitudeDeg.Float64,
+			ElevationFt:          int(heElevationFt.Int64),
+			HeadingDegT:          heHeadingDegT.Float64,
+			DisplacedThresholdFt: int(heDisplacedThresholdFt.Int64),
+		},
+	}, nil
+}
+
+// frequencySelectColumns selects every frequency column, in the order expected by attachFrequenciesBatch
+const frequencySelectColumns = `SELECT id, airport_ref, airport_ident, type, description, frequency_mhz
+	FROM frequencies`
+
+// AttachFrequencies loads the communication frequencies of the given airports,
+// by batches of airportBatchSize airports, and sets them on each airport.
+func (s *SQLiteStore) AttachFrequencies(airports []Airport) error {
+	return forEachAirportBatch(airports, func(batch []Airport) error {
+		return attachFrequenciesBatch(s.db, batch)
+	})
+}
+
+// attachFrequenciesBatch loads the communication frequencies of the given airports in a single query
+func attachFrequenciesBatch(db *sql.DB, airports []Airport) error {
+	args := make([]interface{}, len(airports))
+	for i, a := range airports {
+		args[i] = a.ID
+	}
+
+	query := frequencySelectColumns + " WHERE airport_ref IN (" + SQLPlaceholders(len(airports)) + ") ORDER BY airport_ref, type, frequency_mhz"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	frequenciesByAirport := make(map[int][]Frequency)
+	for rows.Next() {
+		var (
+			id           sql.NullInt64
+			airportRef   sql.NullInt64
+			airportIdent sql.NullString
+			freqType     sql.NullString
+			description  sql.NullString
+			frequencyMHz sql.NullFloat64
+		)
+
+		if err := rows.Scan(&id, &airportRef, &airportIdent, &freqType, &description, &frequencyMHz); err != nil {
+			return err
+		}
+
+		frequency := Frequency{
+			ID:           int(id.Int64),
+			AirportRef:   int(airportRef.Int64),
+			AirportIdent: airportIdent.String,
+			Type:         freqType.String,
+			Description:  description.String,
+			FrequencyMHz: frequencyMHz.Float64,
+		}
+
+		frequenciesByAirport[frequency.AirportRef] = append(frequenciesByAirport[frequency.AirportRef], frequency)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range airports {
+		airports[i].Frequencies = frequenciesByAirport[airports[i].ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import "errors"
+
+// ErrNotFound is returned when the looked up airport or country does not exist
+var ErrNotFound = errors.New("not found")
+
+// AirportStore gives access to the airports of a database.
+// It is implemented by SQLiteStore for the databases built by `ask init`, and by MemoryStore.
+type AirportStore interface {
+	// SearchAirports returns the airports, with their runways, matching the text in their name,
+	// municipality, keywords or codes, optionally only in the given country. Exact code matches
+	// come first, then the larger airports.
+	SearchAirports(text string, country string) ([]Airport, error)
+
+	// AirportByCode returns the airport, with its runways, having the given ICAO code,
+	// or ErrNotFound
+	AirportByCode(code string) (*Airport, error)
+
+	// ResolveCode returns the airport, with its runways, having the given ICAO, IATA, GPS or local code
+	// or ident, and which kind of code matched. It returns ErrNotFound when no airport has the code,
+	// and an AmbiguousCodeError when several airports have it.
+	ResolveCode(code string) (*CodeMatch, error)
+
+	// AirportsInRange returns the airports within rangeNM nautical miles of the origin airport
+	// kept by the filter, closest first.
+	AirportsInRange(origin *Airport, rangeNM float64, filter AirportFilter) ([]ReachableAirport, error)
+
+	// NearestAirports returns the n airports closest to the given point, closest first, with their
+	// distance and bearing from it, among the airports kept by the filter.
+	NearestAirports(lat, lon float64, n int, filter AirportFilter) ([]NearestAirport, error)
+
+	// AirportsInCountry returns the airports of the country, sorted by name.
+	// If types is non-empty, only airports matching those types are returned.
+	AirportsInCountry(country string, types []string) ([]Airport, error)
+
+	// Countries returns every country, sorted by name
+	Countries() ([]Country, error)
+
+	// CountryByCode returns the country having the given ISO code, or ErrNotFound
+	CountryByCode(code string) (*Country, error)
+
+	// ImportStatus returns the last import of every table, sorted by table name
+	ImportStatus() ([]ImportStatus, error)
+}
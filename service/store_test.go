@@ -0,0 +1,302 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	askdb "ask/db"
+)
+
+// testAirports are the airports of the test stores, with their runways longest first as SQLiteStore loads them.
+// Empty fields and zero elevations are stored as NULL in the SQLite store, as ourairports leaves them empty.
+var testAirports = []Airport{
+	{
+		ID: 1, Ident: "LFPG", Type: "large_airport", Name: "Charles de Gaulle", Source: "ourairports", LatitudeDeg: 49.0097, LongitudeDeg: 2.5479,
+		ElevationFt: 392, Continent: "EU", IsoCountry: "FR", ScheduledService: "yes", IcaoCode: "LFPG", IataCode: "CDG",
+		Runways: []Runway{{ID: 11, AirportRef: 1, AirportIdent: "LFPG", LengthFt: 13829}, {ID: 12, AirportRef: 1, AirportIdent: "LFPG", LengthFt: 8858}},
+	},
+	{
+		ID: 2, Ident: "LFPO", Type: "medium_airport", Name: "Orly", Source: "ourairports", LatitudeDeg: 48.7233, LongitudeDeg: 2.3794,
+		ElevationFt: 291, Continent: "EU", IsoCountry: "FR", ScheduledService: "yes", IcaoCode: "LFPO", IataCode: "ORY",
+		Runways: []Runway{{ID: 21, AirportRef: 2, AirportIdent: "LFPO", LengthFt: 11975}},
+	},
+	{
+		ID: 3, Ident: "FR-0001", Type: "small_airport", Name: "Unknown Field", Source: "ourairports", LatitudeDeg: 48.5, LongitudeDeg: 2.0,
+		Continent: "EU", IsoCountry: "FR", ScheduledService: "no", LocalCode: "XYZ1",
+	},
+	{
+		ID: 4, Ident: "LSGG", Type: "large_airport", Name: "Geneva", Source: "ourairports", LatitudeDeg: 46.2381, LongitudeDeg: 6.1089,
+		ElevationFt: 1411, Continent: "EU", IsoCountry: "CH", ScheduledService: "yes", IcaoCode: "LSGG", IataCode: "GVA",
+		Runways: []Runway{{ID: 41, AirportRef: 4, AirportIdent: "LSGG", LengthFt: 12795}},
+	},
+	{
+		ID: 5, Ident: "EGLL", Type: "large_airport", Name: "Heathrow", Source: "ourairports", LatitudeDeg: 51.4706, LongitudeDeg: -0.4619,
+		ElevationFt: 83, Continent: "EU", IsoCountry: "GB", ScheduledService: "yes", IcaoCode: "EGLL", IataCode: "LHR",
+		Runways: []Runway{{ID: 51, AirportRef: 5, AirportIdent: "EGLL", LengthFt: 12799}, {ID: 52, AirportRef: 5, AirportIdent: "EGLL", LengthFt: 3000, Closed: true}},
+	},
+	{
+		ID: 6, Ident: "LFXX", Type: "heliport", Name: "Heliport Without Country", Source: "ourairports", LatitudeDeg: 48.9, LongitudeDeg: 2.3,
+		ElevationFt: 110, Continent: "EU", ScheduledService: "no",
+	},
+	{
+		ID: 7, Ident: "KJFK", Type: "large_airport", Name: "John F Kennedy", Source: "ourairports", LatitudeDeg: 40.6394, LongitudeDeg: -73.7793,
+		ElevationFt: 13, Continent: "NA", IsoCountry: "US", ScheduledService: "yes", IcaoCode: "KJFK", IataCode: "JFK",
+		Runways: []Runway{{ID: 71, AirportRef: 7, AirportIdent: "KJFK", LengthFt: 14511}},
+	},
+}
+
+// unknownClosedRunways are the test runways whose closed flag is left empty, stored as NULL in the SQLite store
+var unknownClosedRunways = map[int]bool{41: true}
+
+// testCountries are the countries of the test stores, sorted by name as both stores list them
+var testCountries = []Country{
+	{ID: 2, Code: "FR", Name: "France", Continent: "EU"},
+	{ID: 1, Code: "CH", Name: "Switzerland", Continent: "EU"},
+	{ID: 3, Code: "GB", Name: "United Kingdom", Continent: "EU"},
+	{ID: 4, Code: "US", Name: "United States", Continent: "NA"},
+}
+
+// nullable returns nil for the zero value, stored as NULL
+func nullable[T comparable](value T) interface{} {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
+
+// newTestSQLiteStore builds a database of the test airports and countries
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "ask.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := askdb.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range testAirports {
+		_, err := db.Exec(`INSERT INTO airports (id, ident, type, name, latitude_deg, longitude_deg, elevation_ft, continent,
+			iso_country, scheduled_service, icao_code, iata_code, local_code, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			a.ID, a.Ident, a.Type, a.Name, a.LatitudeDeg, a.LongitudeDeg, nullable(a.ElevationFt), a.Continent,
+			nullable(a.IsoCountry), a.ScheduledService, nullable(a.IcaoCode), nullable(a.IataCode), nullable(a.LocalCode), a.Source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, r := range a.Runways {
+			var closed interface{} = r.Closed
+			if unknownClosedRunways[r.ID] {
+				closed = nil
+			}
+			_, err := db.Exec(`INSERT INTO runways (id, airport_ref, airport_ident, length_ft, closed) VALUES (?, ?, ?, ?, ?)`,
+				r.ID, r.AirportRef, r.AirportIdent, r.LengthFt, closed)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, c := range testCountries {
+		_, err := db.Exec(`INSERT INTO countries (id, code, name, continent) VALUES (?, ?, ?, ?)`, c.ID, c.Code, c.Name, c.Continent)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewSQLiteStore(db)
+}
+
+// forEachStore runs the test on a MemoryStore and a SQLiteStore of the test airports and countries
+func forEachStore(t *testing.T, test func(t *testing.T, store AirportStore)) {
+	stores := []struct {
+		name  string
+		store AirportStore
+	}{
+		{"memory", NewMemoryStore(testAirports, testCountries, nil)},
+		{"sqlite", newTestSQLiteStore(t)},
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			test(t, s.store)
+		})
+	}
+}
+
+func reachableIdents(airports []ReachableAirport) []string {
+	idents := []string{}
+	for _, a := range airports {
+		idents = append(idents, a.Airport.Ident)
+	}
+	return idents
+}
+
+func TestAirportByCode(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		runways int
+		err     error
+	}{
+		{code: "LFPG", want: "LFPG", runways: 2},
+		{code: "egll", want: "EGLL", runways: 2},
+		{code: "CDG", err: ErrNotFound},
+		{code: "ZZZZ", err: ErrNotFound},
+	}
+
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		for _, tt := range tests {
+			airport, err := store.AirportByCode(tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("AirportByCode(%q) error = %v, want %v", tt.code, err, tt.err)
+			}
+			if tt.err != nil {
+				continue
+			}
+			if airport.Ident != tt.want || len(airport.Runways) != tt.runways {
+				t.Errorf("AirportByCode(%q) = %s with %d runways, want %s with %d runways",
+					tt.code, airport.Ident, len(airport.Runways), tt.want, tt.runways)
+			}
+		}
+	})
+}
+
+func TestResolveCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		kind CodeKind
+		err  error
+	}{
+		{code: "LFPG", want: "LFPG", kind: CodeICAO},
+		{code: "cdg", want: "LFPG", kind: CodeIATA},
+		{code: "FR-0001", want: "FR-0001", kind: CodeIdent},
+		{code: "XYZ1", want: "FR-0001", kind: CodeLocal},
+		{code: "ZZZZ", err: ErrNotFound},
+		{code: "", err: ErrNotFound},
+	}
+
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		for _, tt := range tests {
+			match, err := store.ResolveCode(tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ResolveCode(%q) error = %v, want %v", tt.code, err, tt.err)
+			}
+			if tt.err != nil {
+				continue
+			}
+			if match.Airport.Ident != tt.want || match.Kind != tt.kind {
+				t.Errorf("ResolveCode(%q) = %s by %s, want %s by %s", tt.code, match.Airport.Ident, match.Kind, tt.want, tt.kind)
+			}
+		}
+	})
+}
+
+func TestAirportsInRange(t *testing.T) {
+	origin := testAirports[0]
+
+	tests := []struct {
+		name    string
+		rangeNM float64
+		filter  AirportFilter
+		want    []string
+	}{
+		{name: "nearby", rangeNM: 50, want: []string{"LFXX", "LFPO", "FR-0001"}},
+		{name: "wide", rangeNM: 500, want: []string{"LFXX", "LFPO", "FR-0001", "EGLL", "LSGG"}},
+		{name: "types", rangeNM: 500, filter: AirportFilter{Types: []string{"large_airport"}}, want: []string{"EGLL", "LSGG"}},
+		{name: "none", rangeNM: 1, want: []string{}},
+	}
+
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		for _, tt := range tests {
+			airports, err := store.AirportsInRange(&origin, tt.rangeNM, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reachableIdents(airports); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: AirportsInRange() = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestNearestAirports(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		airports, err := store.NearestAirports(48.8566, 2.3522, 2, AirportFilter{Types: []string{"large_airport"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(airports) != 2 || airports[0].Airport.Ident != "LFPG" || airports[1].Airport.Ident != "EGLL" {
+			t.Fatalf("NearestAirports() = %v, want LFPG and EGLL", airports)
+		}
+		// Charles de Gaulle is north-east of Paris
+		if airports[0].BearingDeg < 0 || airports[0].BearingDeg > 90 {
+			t.Errorf("bearing to LFPG = %g, want between 0 and 90", airports[0].BearingDeg)
+		}
+	})
+}
+
+func TestAirportsInCountry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		airports, err := store.AirportsInCountry("fr", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var idents []string
+		for _, a := range airports {
+			idents = append(idents, a.Ident)
+		}
+		if want := []string{"LFPG", "LFPO", "FR-0001"}; !reflect.DeepEqual(idents, want) {
+			t.Errorf("AirportsInCountry() = %v, want %v", idents, want)
+		}
+	})
+}
+
+func TestCountries(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store AirportStore) {
+		countries, err := store.Countries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(countries, testCountries) {
+			t.Errorf("Countries() = %v, want %v", countries, testCountries)
+		}
+
+		if _, err := store.CountryByCode("XX"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CountryByCode(XX) error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// TestStoresAgree checks that both stores return the same airports, field by field
+func TestStoresAgree(t *testing.T) {
+	memory := NewMemoryStore(testAirports, testCountries, nil)
+	sqlite := newTestSQLiteStore(t)
+
+	for _, code := range []string{"LFPG", "CDG", "XYZ1"} {
+		want, _ := memory.ResolveCode(code)
+		got, _ := sqlite.ResolveCode(code)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ResolveCode(%q): sqlite = %+v, memory = %+v", code, got, want)
+		}
+	}
+
+	want, _ := memory.AirportsInRange(&testAirports[0], 500, AirportFilter{})
+	got, _ := sqlite.AirportsInRange(&testAirports[0], 500, AirportFilter{})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AirportsInRange(): sqlite = %+v, memory = %+v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"ask/timezone"
+)
+
+// AirportTime is the current local time at an airport
+type AirportTime struct {
+	ICAO      string   `json:"icao"`
+	Ident     string   `json:"ident"`
+	Name      string   `json:"name"`
+	Match     CodeKind `json:"match,omitempty"`
+	Timezone  string   `json:"timezone"`
+	LocalTime string   `json:"local_time"`
+	UTCOffset string   `json:"utc_offset"`
+}
+
+// LocalTime returns the current local time at the airport
+func LocalTime(airport *Airport) (*AirportTime, error) {
+	// The timezone is resolved at import, databases imported before that need a lookup
+	timezoneName := airport.Timezone
+	if timezoneName == "" {
+		var err error
+		timezoneName, err = timezone.Lookup(airport.LatitudeDeg, airport.LongitudeDeg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone data: %w", err)
+		}
+	}
+	if timezoneName == "" {
+		return nil, fmt.Errorf("could not determine timezone for airport location")
+	}
+
+	loc, err := time.LoadLocation(timezoneName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %s: %w", timezoneName, err)
+	}
+
+	now := time.Now().In(loc)
+
+	return &AirportTime{
+		ICAO:      airport.IcaoCode,
+		Ident:     airport.Ident,
+		Name:      airport.Name,
+		Timezone:  timezoneName,
+		LocalTime: now.Format(time.RFC3339),
+		UTCOffset: now.Format("-07:00"),
+	}, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+// airportTypeRank orders the airport types from the largest to the smallest, as airportSearchOrder does
+var airportTypeRank = map[string]int{
+	"large_airport":  0,
+	"medium_airport": 1,
+	"small_airport":  2,
+	"seaplane_base":  3,
+	"heliport":       4,
+}
+
+// TypeRank returns the rank of the airport type, from 0 for large airports
+// to the largest rank for closed airports and balloonports
+func TypeRank(airportType string) int {
+	if rank, ok := airportTypeRank[airportType]; ok {
+		return rank
+	}
+	return len(airportTypeRank)
+}
+
+// Airport is an airport, with its runways and frequencies when they were loaded
+type Airport struct {
+	ID               int         `json:"id"`
+	Ident            string      `json:"ident"`
+	Type             string      `json:"type"`
+	Name             string      `json:"name"`
+	LatitudeDeg      float64     `json:"latitude_deg"`
+	LongitudeDeg     float64     `json:"longitude_deg"`
+	ElevationFt      int         `json:"elevation_ft"`
+	Continent        string      `json:"continent"`
+	IsoCountry       string      `json:"iso_country"`
+	IsoRegion        string      `json:"iso_region"`
+	RegionName       string      `json:"region_name"`
+	Municipality     string      `json:"municipality"`
+	ScheduledService string      `json:"scheduled_service"`
+	IcaoCode         string      `json:"icao_code"`
+	IataCode         string      `json:"iata_code"`
+	GpsCode          string      `json:"gps_code"`
+	LocalCode        string      `json:"local_code"`
+	HomeLink         string      `json:"home_link"`
+	WikipediaLink    string      `json:"wikipedia_link"`
+	Keywords         string      `json:"keywords"`
+	Source           string      `json:"source"`
+	Timezone         string      `json:"timezone"`
+	Runways          []Runway    `json:"runways,omitempty"`
+	Frequencies      []Frequency `json:"frequencies,omitempty"`
+}
+
+type RunwayEnd struct {
+	Ident                string  `json:"ident"`
+	LatitudeDeg          float64 `json:"latitude_deg"`
+	LongitudeDeg         float64 `json:"longitude_deg"`
+	ElevationFt          int     `json:"elevation_ft"`
+	HeadingDegT          float64 `json:"heading_degT"`
+	DisplacedThresholdFt int     `json:"displaced_threshold_ft"`
+}
+
+type Runway struct {
+	ID           int       `json:"id"`
+	AirportRef   int       `json:"airport_ref"`
+	AirportIdent string    `json:"airport_ident"`
+	LengthFt     int       `json:"length_ft"`
+	WidthFt      int       `json:"width_ft"`
+	Surface      string    `json:"surface"`
+	Lighted      bool      `json:"lighted"`
+	Closed       bool      `json:"closed"`
+	LowEnd       RunwayEnd `json:"le"`
+	HighEnd      RunwayEnd `json:"he"`
+}
+
+type Country struct {
+	ID            int    `json:"id"`
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	Continent     string `json:"continent"`
+	WikipediaLink string `json:"wikipedia_link"`
+	Keywords      string `json:"keywords"`
+}
+
+type Frequency struct {
+	ID           int     `json:"id"`
+	AirportRef   int     `json:"airport_ref"`
+	AirportIdent string  `json:"airport_ident"`
+	Type         string  `json:"type"`
+	Description  string  `json:"description"`
+	FrequencyMHz float64 `json:"frequency_mhz"`
+}
+
+// ImportStatus is the last import of a table of the database
+type ImportStatus struct {
+	TableName       string `json:"table_name"`
+	LastImportDate  string `json:"last_import_date"`
+	GitCommitHash   string `json:"git_commit_hash"`
+	GitCommitDate   string `json:"git_commit_date"`
+	RecordCount     int    `json:"record_count"`
+	ContentChecksum string `json:"content_checksum,omitempty"`
+	SourceURL       string `json:"source_url,omitempty"`
+	PinnedRef       string `json:"pinned_ref,omitempty"`
+}
+
+// ReachableAirport is an airport found within range of an origin, with its distance to it
+type ReachableAirport struct {
+	Airport    Airport `json:"airport"`
+	DistanceNM float64 `json:"distance_nm"`
+}
+
+// NearestAirport is an airport found near a point, with its distance and true bearing from it
+type NearestAirport struct {
+	Airport    Airport `json:"airport"`
+	DistanceNM float64 `json:"distance_nm"`
+	BearingDeg float64 `json:"bearing_deg"`
+}
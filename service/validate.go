@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxRangeNM is the longest range accepted around an airport, half the Earth circumference
+const MaxRangeNM = 10800
+
+var validAirportTypes = map[string]bool{
+	"large_airport":  true,
+	"medium_airport": true,
+	"small_airport":  true,
+	"heliport":       true,
+	"seaplane_base":  true,
+	"closed":         true,
+	"balloonport":    true,
+}
+
+var validContinents = map[string]bool{
+	"AF": true,
+	"AN": true,
+	"AS": true,
+	"EU": true,
+	"NA": true,
+	"OC": true,
+	"SA": true,
+}
+
+// IsValidAirportCode validates that the airport code, of any kind (ICAO, IATA, GPS, local or ident),
+// is 1 to 10 letters, digits or hyphens
+func IsValidAirportCode(code string) bool {
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9\-]{1,10}$`, code)
+	return matched
+}
+
+// IsValidSearchText validates that the airport search text contains only allowed characters
+func IsValidSearchText(text string) bool {
+	// Allow letters, digits (for codes), spaces, hyphens, apostrophes, and common punctuation for airport names
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9\s\-'\.]+$`, text)
+	return matched
+}
+
+// IsValidCountryCode validates that the country code contains only letters
+func IsValidCountryCode(code string) bool {
+	matched, _ := regexp.MatchString(`^[a-zA-Z]+$`, code)
+	return matched
+}
+
+// IsValidRange validates that the range string is a positive number of nautical miles up to MaxRangeNM
+func IsValidRange(rangeStr string) (float64, bool) {
+	rangeNM, err := strconv.ParseFloat(rangeStr, 64)
+	if err != nil {
+		return 0, false
+	}
+	if rangeNM <= 0 || rangeNM > MaxRangeNM {
+		return 0, false
+	}
+	return rangeNM, true
+}
+
+// ParseAirportTypes splits a comma-separated type string, trims whitespace,
+// validates each type, and returns the list. Returns false if any type is invalid.
+func ParseAirportTypes(typesStr string) ([]string, bool) {
+	if typesStr == "" {
+		return nil, true
+	}
+	parts := strings.Split(typesStr, ",")
+	types := make([]string, 0, len(parts))
+	for _, p := range parts {
+		t := strings.TrimSpace(p)
+		if t == "" {
+			continue
+		}
+		if !validAirportTypes[t] {
+			return nil, false
+		}
+		types = append(types, t)
+	}
+	return types, true
+}
+
+// ParseCountryCodes splits a comma-separated country code string, trims whitespace,
+// upper-cases and validates each code. Returns false if any code is invalid.
+func ParseCountryCodes(codesStr string) ([]string, bool) {
+	return parseCodes(codesStr, IsValidCountryCode)
+}
+
+// ParseContinents splits a comma-separated continent code string, trims whitespace,
+// upper-cases and validates each code. Returns false if any code is invalid.
+func ParseContinents(codesStr string) ([]string, bool) {
+	return parseCodes(codesStr, func(code string) bool { return validContinents[code] })
+}
+
+// parseCodes splits a comma-separated code string, trims whitespace, upper-cases and validates each code
+func parseCodes(codesStr string, valid func(string) bool) ([]string, bool) {
+	if codesStr == "" {
+		return nil, true
+	}
+	parts := strings.Split(codesStr, ",")
+	codes := make([]string, 0, len(parts))
+	for _, p := range parts {
+		c := strings.ToUpper(strings.TrimSpace(p))
+		if c == "" {
+			continue
+		}
+		if !valid(c) {
+			return nil, false
+		}
+		codes = append(codes, c)
+	}
+	return codes, true
+}
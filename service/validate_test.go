@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsValidAirportCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"LFPG": true, "cdg": true, "US-1234": true, "00AK": true,
+		"": false, "LFPG LFPO": false, "ABCDEFGHIJK": false, "L'FPG": false,
+	} {
+		if got := IsValidAirportCode(code); got != want {
+			t.Errorf("IsValidAirportCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestIsValidRange(t *testing.T) {
+	tests := []struct {
+		rangeStr string
+		want     float64
+		ok       bool
+	}{
+		{rangeStr: "250", want: 250, ok: true},
+		{rangeStr: "0.5", want: 0.5, ok: true},
+		{rangeStr: "10800", want: 10800, ok: true},
+		{rangeStr: "10801"},
+		{rangeStr: "0"},
+		{rangeStr: "-5"},
+		{rangeStr: "far"},
+		{rangeStr: ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := IsValidRange(tt.rangeStr)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("IsValidRange(%q) = %g, %v, want %g, %v", tt.rangeStr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) ([]string, bool)
+		input string
+		want  []string
+		ok    bool
+	}{
+		{name: "types", parse: ParseAirportTypes, input: "large_airport, heliport", want: []string{"large_airport", "heliport"}, ok: true},
+		{name: "types", parse: ParseAirportTypes, input: "airport"},
+		{name: "types", parse: ParseAirportTypes, input: "", ok: true},
+		{name: "countries", parse: ParseCountryCodes, input: "fr,,ch ", want: []string{"FR", "CH"}, ok: true},
+		{name: "countries", parse: ParseCountryCodes, input: "F1"},
+		{name: "continents", parse: ParseContinents, input: "eu,NA", want: []string{"EU", "NA"}, ok: true},
+		{name: "continents", parse: ParseContinents, input: "EU,XX"},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.parse(tt.input)
+		if ok != tt.ok || (tt.want != nil && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: parse(%q) = %v, %v, want %v, %v", tt.name, tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2024 Nicolas Dufour
+*/
+package timezone
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ringsaturn/tzf"
+	tzfrellite "github.com/ringsaturn/tzf-rel-lite"
+	pb "github.com/ringsaturn/tzf/gen/go/tzf/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	finderOnce sync.Once
+	finder     tzf.F
+	finderErr  error
+)
+
+// loadFinder unmarshals the embedded timezone boundaries. It is costly, hence only
+// done on the first lookup rather than when the program starts.
+func loadFinder() (tzf.F, error) {
+	finderOnce.Do(func() {
+		input := &pb.CompressedTimezones{}
+		if err := proto.Unmarshal(tzfrellite.LiteCompressData, input); err != nil {
+			finderErr = fmt.Errorf("failed to unmarshal compressed timezone data: %w", err)
+			return
+		}
+		finder, finderErr = tzf.NewFinderFromCompressed(input)
+		if finderErr != nil {
+			finderErr = fmt.Errorf("failed to initialize timezone finder: %w", finderErr)
+		}
+	})
+	return finder, finderErr
+}
+
+// Lookup returns the IANA name of the timezone at the given position, "" when there is none
+func Lookup(lat, lon float64) (string, error) {
+	f, err := loadFinder()
+	if err != nil {
+		return "", err
+	}
+	return f.GetTimezoneName(lon, lat), nil
+}